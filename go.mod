@@ -5,6 +5,8 @@ go 1.14
 require (
 	github.com/btcsuite/btcd v0.20.1-beta
 	github.com/btcsuite/btcutil v1.0.2
+	github.com/btcsuite/btcutil/psbt v1.0.2
 	github.com/vulpemventures/go-elements v0.0.4-0.20200707142930-e477e50f71e9
-	golang.org/x/crypto v0.0.0-20200707235045-ab33eee955e0 // indirect
+	github.com/vulpemventures/go-secp256k1-zkp v1.0.1
+	golang.org/x/crypto v0.0.0-20200707235045-ab33eee955e0
 )
@@ -0,0 +1,133 @@
+// Package testutil provides the fixtures shared by the tests of the packages
+// handling confidential utxos.
+package testutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/tiero/ocean/internal/bufferutil"
+	"github.com/tiero/ocean/pkg/explorer"
+	"github.com/tiero/ocean/pkg/keypair"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/pset"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+type utxo struct {
+	hash            string
+	index           uint32
+	value           uint64
+	asset           string
+	valueCommitment string
+	assetCommitment string
+	nonce           []byte
+	script          []byte
+	rangeProof      []byte
+	surjectionProof []byte
+}
+
+func (u utxo) Hash() string            { return u.hash }
+func (u utxo) Index() uint32           { return u.index }
+func (u utxo) Value() uint64           { return u.value }
+func (u utxo) Asset() string           { return u.asset }
+func (u utxo) ValueCommitment() string { return u.valueCommitment }
+func (u utxo) AssetCommitment() string { return u.assetCommitment }
+func (u utxo) Nonce() []byte           { return u.nonce }
+func (u utxo) Script() []byte          { return u.script }
+func (u utxo) RangeProof() []byte      { return u.rangeProof }
+func (u utxo) SurjectionProof() []byte { return u.surjectionProof }
+
+// NewExplicitUtxo returns an unconfidential utxo without script, like the
+// ones returned by the blockstream explorer, with the given id repeated as
+// hash
+func NewExplicitUtxo(asset string, value uint64, id byte) explorer.Utxo {
+	return utxo{
+		hash:  hex.EncodeToString(bytes.Repeat([]byte{id}, 32)),
+		value: value,
+		asset: asset,
+	}
+}
+
+// NewConfidentialUtxo returns a confidential utxo locked by the given script
+// and blinded with the public key of the given blinding key pair. The output
+// is blinded with the go-elements blinder, since the partial package can not
+// be imported by the fixtures of its own tests
+func NewConfidentialUtxo(t *testing.T, asset string, value uint64, script []byte, blindingKey *keypair.KeyPair) explorer.Utxo {
+	t.Helper()
+	assetHash, err := hex.DecodeString(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elementsAsset := append([]byte{0x01}, bufferutil.ReverseBytes(assetHash)...)
+	elementsValue, err := confidential.SatoshiToElementsValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := transaction.NewTxInput(bytes.Repeat([]byte{0xff}, 32), 0)
+	output := transaction.NewTxOutput(elementsAsset, elementsValue[:], script)
+	p, err := pset.New([]*transaction.TxInput{input}, []*transaction.TxOutput{output}, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Inputs[0].WitnessUtxo = transaction.NewTxOutput(elementsAsset, elementsValue[:], script)
+
+	blinder, err := pset.NewBlinder(
+		p,
+		[][]byte{blindingKey.PrivateKey.Serialize()},
+		[][]byte{blindingKey.PublicKey.SerializeCompressed()},
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blinder.Blind(); err != nil {
+		t.Fatal(err)
+	}
+
+	blinded := p.UnsignedTx.Outputs[0]
+	txHash := p.UnsignedTx.TxHash()
+	return utxo{
+		hash:            txHash.String(),
+		valueCommitment: hex.EncodeToString(blinded.Value),
+		assetCommitment: hex.EncodeToString(blinded.Asset),
+		nonce:           blinded.Nonce,
+		script:          blinded.Script,
+		rangeProof:      blinded.RangeProof,
+		surjectionProof: blinded.SurjectionProof,
+	}
+}
+
+// UnblindOutput returns the asset and value of the given confidential output,
+// unblinded with the given blinding private key
+func UnblindOutput(t *testing.T, output *transaction.TxOutput, blindingKey []byte) *confidential.UnblindOutputResult {
+	t.Helper()
+	nonce, err := confidential.NonceHash(output.Nonce, blindingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unblinded, err := confidential.UnblindOutput(confidential.UnblindOutputArg{
+		Nonce:           nonce,
+		Rangeproof:      output.RangeProof,
+		ValueCommitment: output.Value,
+		AssetCommitment: output.Asset,
+		ScriptPubkey:    output.Script,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unblinded
+}
+
+// NewKeyPair returns the key pair of the given hex private key
+func NewKeyPair(t *testing.T, privateKeyHex string) *keypair.KeyPair {
+	t.Helper()
+	kp, err := keypair.FromPrivateKey(privateKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kp
+}
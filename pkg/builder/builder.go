@@ -0,0 +1,307 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tiero/ocean/pkg/coinselect"
+	"github.com/tiero/ocean/pkg/confidential"
+	"github.com/tiero/ocean/pkg/explorer"
+	"github.com/tiero/ocean/pkg/partial"
+	"github.com/tiero/ocean/pkg/unblinding"
+	addressPackage "github.com/vulpemventures/go-elements/address"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+const (
+	// MinFeeRate is the minimum relay fee rate in satoshi per virtual byte
+	MinFeeRate = 0.1
+	// maxFundingAttempts is the max number of times coins are selected again
+	// to cover an increased fee
+	maxFundingAttempts = 10
+)
+
+// Recipient defines an output of the transaction to build
+type Recipient struct {
+	Address string
+	Asset   string
+	Value   uint64
+}
+
+// BuildArgs defines the arguments to build a transaction
+type BuildArgs struct {
+	Recipients []Recipient
+	// Address is the address whose utxos fund the transaction
+	Address string
+	// BlindingKey is the private blinding key to unblind the utxos of Address
+	BlindingKey []byte
+	// ChangeAddress receives the change of every spent asset
+	ChangeAddress string
+	// FeeRate is the fee rate in satoshi per virtual byte. If 0, the medium
+	// estimation of the explorer is used
+	FeeRate float64
+}
+
+// Builder builds transactions funded with the utxos of an address
+type Builder struct {
+	Explorer explorer.Explorer
+	Network  *network.Network
+}
+
+// NewBuilder returns a Builder fetching utxos with the given explorer
+func NewBuilder(e explorer.Explorer, net *network.Network) *Builder {
+	currentNetwork := &network.Liquid
+	if net != nil {
+		currentNetwork = net
+	}
+	return &Builder{Explorer: e, Network: currentNetwork}
+}
+
+// Build returns the unsigned Partial paying the recipients with the utxos of
+// the funding address. Coins are selected per asset and the change of each
+// asset goes back to the change address. The fee output is added and the
+// outputs to confidential addresses are blinded with the keys embedded in the
+// addresses, while the others are left explicit.
+func (b *Builder) Build(args BuildArgs) (*partial.Partial, error) {
+	if err := b.validate(args); err != nil {
+		return nil, err
+	}
+
+	feeRate := args.FeeRate
+	if feeRate == 0 {
+		estimation, err := b.Explorer.EstimateFees()
+		if err != nil {
+			return nil, err
+		}
+		feeRate = estimation.Medium()
+	}
+	if feeRate < MinFeeRate {
+		feeRate = MinFeeRate
+	}
+
+	utxos, err := b.Explorer.GetUnspents(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	// utxos are unblinded once and reused across funding attempts
+	coins := &coinselect.Coins{
+		Utxos:     utxos,
+		Unblinder: unblinding.NewCachedUnblinder(unblinding.StaticKey(args.BlindingKey)),
+	}
+
+	// amounts to send are grouped by asset, in order of first appearance, with
+	// the policy asset always last
+	assets := make([]string, 0)
+	amounts := make(map[string]uint64)
+	for _, recipient := range args.Recipients {
+		if _, ok := amounts[recipient.Asset]; !ok && recipient.Asset != b.Network.AssetID {
+			assets = append(assets, recipient.Asset)
+		}
+		amounts[recipient.Asset] += recipient.Value
+	}
+	assets = append(assets, b.Network.AssetID)
+
+	// policy asset coins are selected until they cover the estimated fee
+	var fee uint64
+	for attempt := 0; attempt < maxFundingAttempts; attempt++ {
+		targets := make(map[string]uint64, len(assets))
+		for _, asset := range assets {
+			targets[asset] = amounts[asset]
+		}
+		targets[b.Network.AssetID] += fee
+
+		unspents, changes, excess, err := coins.CoinSelectMany(targets)
+		if err != nil {
+			return nil, err
+		}
+		// the fee output is zero until set, its amount and any excess of the
+		// selection are left in the change
+		changes[b.Network.AssetID] += fee + excess
+
+		p, changeIndex, blindingKeys, err := b.newPartial(args, unspents, assets, changes, 0)
+		if err != nil {
+			return nil, err
+		}
+		// only the outputs with a blinding key are going to be blinded
+		estimateArgs := partial.EstimateArgs{}
+		for index := range blindingKeys {
+			estimateArgs.BlindedOutputs = append(estimateArgs.BlindedOutputs, index)
+		}
+
+		vsize, err := p.EstimateVirtualSize(estimateArgs)
+		if err != nil {
+			return nil, err
+		}
+		estimatedFee := uint64(math.Ceil(float64(vsize) * feeRate))
+		if changes[b.Network.AssetID] < estimatedFee {
+			fee = estimatedFee
+			continue
+		}
+
+		if changes[b.Network.AssetID] == estimatedFee {
+			// the coins cover the fee exactly, the transaction is built without
+			// the policy asset change, paying the estimated fee
+			changes[b.Network.AssetID] = 0
+			p, _, blindingKeys, err = b.newPartial(args, unspents, assets, changes, estimatedFee)
+			if err != nil {
+				return nil, err
+			}
+		} else if _, err := p.SetFeeWithRate(feeRate, changeIndex, estimateArgs); err != nil {
+			return nil, err
+		}
+		if len(blindingKeys) > 0 {
+			err := p.Blind(partial.BlindArgs{
+				BlindingPrivateKeys: blindingPrivateKeys(p, args.BlindingKey),
+				OutputBlindingKeys:  blindingKeys,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := p.VerifyBlinding(nil); err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	}
+
+	return nil, errors.New("unable to select coins covering the fee")
+}
+
+// newPartial returns the Partial spending the selected utxos to the
+// recipients, with the non zero change outputs of the given assets and the fee
+// output. The index of the policy asset change output, -1 if missing, and the
+// blinding public keys of the outputs to confidential addresses are returned
+// as well
+func (b *Builder) newPartial(args BuildArgs, unspents []explorer.Utxo, assets []string, changes map[string]uint64, fee uint64) (*partial.Partial, int, map[int][]byte, error) {
+	fundingScript, err := addressPackage.ToOutputScript(args.Address, *b.Network)
+	if err != nil {
+		return nil, -1, nil, err
+	}
+	changeScript, changeBlindingKey, err := b.outputScriptAndKey(args.ChangeAddress)
+	if err != nil {
+		return nil, -1, nil, err
+	}
+
+	p := partial.NewPartial(b.Network)
+	blindingKeys := make(map[int][]byte)
+	addOutput := func(asset string, value uint64, script, blindingKey []byte) error {
+		blinded := len(blindingKey) > 0
+		if err := p.AddOutput(asset, value, script, blinded); err != nil {
+			return err
+		}
+		if blinded {
+			blindingKeys[len(p.Data.Outputs)-1] = blindingKey
+		}
+		return nil
+	}
+
+	for _, recipient := range args.Recipients {
+		script, blindingKey, err := b.outputScriptAndKey(recipient.Address)
+		if err != nil {
+			return nil, -1, nil, err
+		}
+		if err := addOutput(recipient.Asset, recipient.Value, script, blindingKey); err != nil {
+			return nil, -1, nil, err
+		}
+	}
+
+	changeIndex := -1
+	for _, asset := range assets {
+		if changes[asset] == 0 {
+			continue
+		}
+		if err := addOutput(asset, changes[asset], changeScript, changeBlindingKey); err != nil {
+			return nil, -1, nil, err
+		}
+		if asset == b.Network.AssetID {
+			changeIndex = len(p.Data.Outputs) - 1
+		}
+	}
+
+	for _, utxo := range unspents {
+		if len(blindingKeys) == 0 && len(utxo.AssetCommitment()) > 0 {
+			return nil, -1, nil, errors.New(
+				"confidential utxos can not be spent only to unconfidential addresses",
+			)
+		}
+		if err := addInput(p, utxo, fundingScript); err != nil {
+			return nil, -1, nil, err
+		}
+	}
+
+	if err := p.AddFeeOutput(fee); err != nil {
+		return nil, -1, nil, err
+	}
+	return p, changeIndex, blindingKeys, nil
+}
+
+// outputScriptAndKey returns the output script of the given address and, if
+// confidential, its blinding public key
+func (b *Builder) outputScriptAndKey(address string) ([]byte, []byte, error) {
+	decoded, err := confidential.Decode(address, *b.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decoded.Script, decoded.BlindingKey, nil
+}
+
+// blindingPrivateKeys returns the private blinding key of the funding
+// address for each input of the Partial
+func blindingPrivateKeys(p *partial.Partial, blindingKey []byte) [][]byte {
+	keys := make([][]byte, 0, len(p.Data.Inputs))
+	for range p.Data.Inputs {
+		keys = append(keys, blindingKey)
+	}
+	return keys
+}
+
+func (b *Builder) validate(args BuildArgs) error {
+	if len(args.Recipients) == 0 {
+		return errors.New("recipients are missing")
+	}
+	for i, recipient := range args.Recipients {
+		if recipient.Value == 0 {
+			return fmt.Errorf("recipient %d: value must be greater than 0", i)
+		}
+		if len(recipient.Asset) != 64 {
+			return fmt.Errorf("recipient %d: invalid asset", i)
+		}
+	}
+	if args.Address == "" {
+		return errors.New("funding address is missing")
+	}
+	if args.ChangeAddress == "" {
+		return errors.New("change address is missing")
+	}
+	if args.FeeRate < 0 {
+		return errors.New("fee rate must not be negative")
+	}
+	return nil
+}
+
+// addInput adds the given utxo to the Partial. The explorer may omit the
+// script of unconfidential utxos, in that case the one of the funding address
+// is used
+func addInput(p *partial.Partial, utxo explorer.Utxo, fundingScript []byte) error {
+	script := utxo.Script()
+	if len(script) == 0 {
+		script = fundingScript
+	}
+
+	if len(utxo.AssetCommitment()) > 0 && len(utxo.ValueCommitment()) > 0 {
+		return p.AddBlindedInput(utxo.Hash(), utxo.Index(), &partial.ConfidentialWitnessUtxo{
+			AssetCommitment: utxo.AssetCommitment(),
+			ValueCommitment: utxo.ValueCommitment(),
+			Script:          script,
+			Nonce:           utxo.Nonce(),
+			RangeProof:      utxo.RangeProof(),
+			SurjectionProof: utxo.SurjectionProof(),
+		}, nil)
+	}
+	return p.AddInput(utxo.Hash(), utxo.Index(), &partial.WitnessUtxo{
+		Asset:  utxo.Asset(),
+		Value:  utxo.Value(),
+		Script: script,
+	}, nil)
+}
@@ -0,0 +1,207 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/tiero/ocean/internal/testutil"
+	"github.com/tiero/ocean/pkg/explorer"
+	"github.com/tiero/ocean/pkg/partial"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/payment"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+const aliceHex = "bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86"
+const aliceBlindHex = "dd65e215154c13b1c14f9dc0aa7cfc1f40414f214bd0c5dfe2d370880bdf8356"
+const bobHex = "1804e76aa3016013bc9969103554668913cf697c03c23aecb28136d0e0ac16f0"
+const bobBlindHex = "fd9123214784758c69351f45aebf3c719533a05c5fa017a466b4f31328487552"
+
+var usdt = hex.EncodeToString(bytes.Repeat([]byte{0x02}, 32))
+
+func TestBuild(t *testing.T) {
+	alice, aliceBlind := testutil.NewKeyPair(t, aliceHex), testutil.NewKeyPair(t, aliceBlindHex)
+	bob, bobBlind := testutil.NewKeyPair(t, bobHex), testutil.NewKeyPair(t, bobBlindHex)
+
+	alicePay := payment.FromPublicKey(alice.PublicKey, &network.Regtest, aliceBlind.PublicKey)
+	bobPay := payment.FromPublicKey(bob.PublicKey, &network.Regtest, bobBlind.PublicKey)
+	aliceAddress, _ := alicePay.WitnessPubKeyHash()
+	aliceConfAddress, _ := alicePay.ConfidentialWitnessPubKeyHash()
+	bobAddress, _ := bobPay.WitnessPubKeyHash()
+	bobConfAddress, _ := bobPay.ConfidentialWitnessPubKeyHash()
+
+	explicitUtxos := []explorer.Utxo{
+		testutil.NewExplicitUtxo(network.Regtest.AssetID, 100000000, 1),
+		testutil.NewExplicitUtxo(usdt, 50000, 2),
+		testutil.NewExplicitUtxo(network.Regtest.AssetID, 1000, 3),
+	}
+	confidentialUtxos := []explorer.Utxo{
+		testutil.NewConfidentialUtxo(t, network.Regtest.AssetID, 100000000, alicePay.WitnessScript, aliceBlind),
+		testutil.NewConfidentialUtxo(t, usdt, 50000, alicePay.WitnessScript, aliceBlind),
+	}
+
+	// outputs are recipients, change for each asset and fee
+	tests := []struct {
+		name             string
+		utxos            []explorer.Utxo
+		recipient        string
+		changeAddress    string
+		wantConfidential []bool
+		wantErr          bool
+	}{
+		{"unconfidential", explicitUtxos, bobAddress, aliceAddress, []bool{false, false, false, false}, false},
+		{"confidential", confidentialUtxos, bobConfAddress, aliceConfAddress, []bool{true, true, true, true}, false},
+		{"confidential from explicit utxos", explicitUtxos, bobConfAddress, aliceConfAddress, []bool{true, true, true, true}, false},
+		{"confidential recipient", explicitUtxos, bobConfAddress, aliceAddress, []bool{true, true, false, false}, false},
+		{"confidential change", confidentialUtxos, bobAddress, aliceConfAddress, []bool{false, false, true, true}, false},
+		{"confidential utxos to unconfidential", confidentialUtxos, bobAddress, aliceAddress, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuilder(&mockExplorer{utxos: tt.utxos}, &network.Regtest)
+			p, err := b.Build(BuildArgs{
+				Recipients: []Recipient{
+					{tt.recipient, network.Regtest.AssetID, 60000000},
+					{tt.recipient, usdt, 30000},
+				},
+				Address:       aliceConfAddress,
+				BlindingKey:   aliceBlind.PrivateKey.Serialize(),
+				ChangeAddress: tt.changeAddress,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Should have failed building transaction")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(p.Data.Outputs) != 5 {
+				t.Fatalf("Got %d outputs, expected 5", len(p.Data.Outputs))
+			}
+			for i, output := range p.Data.UnsignedTx.Outputs[:4] {
+				if output.IsConfidential() != tt.wantConfidential[i] {
+					t.Fatalf("Output %d: got confidential %v, expected %v", i, output.IsConfidential(), tt.wantConfidential[i])
+				}
+			}
+			if tt.wantConfidential[0] {
+				unblinded := testutil.UnblindOutput(t, p.Data.UnsignedTx.Outputs[0], bobBlind.PrivateKey.Serialize())
+				if unblinded.Value != 60000000 {
+					t.Fatalf("Got value %d, expected 60000000", unblinded.Value)
+				}
+			}
+
+			for i := range p.Data.Inputs {
+				if err := p.SignWithPrivateKey(i, alice); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.FinalizeAll(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Extract(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBuildExactFee(t *testing.T) {
+	alice, aliceBlind := testutil.NewKeyPair(t, aliceHex), testutil.NewKeyPair(t, aliceBlindHex)
+	bob := testutil.NewKeyPair(t, bobHex)
+
+	alicePay := payment.FromPublicKey(alice.PublicKey, &network.Regtest, aliceBlind.PublicKey)
+	bobPay := payment.FromPublicKey(bob.PublicKey, &network.Regtest, nil)
+	aliceAddress, _ := alicePay.WitnessPubKeyHash()
+	bobAddress, _ := bobPay.WitnessPubKeyHash()
+
+	build := func(utxoValue uint64) (*partial.Partial, error) {
+		b := NewBuilder(&mockExplorer{utxos: []explorer.Utxo{
+			testutil.NewExplicitUtxo(network.Regtest.AssetID, utxoValue, 1),
+		}}, &network.Regtest)
+		return b.Build(BuildArgs{
+			Recipients:    []Recipient{{bobAddress, network.Regtest.AssetID, 60000000}},
+			Address:       aliceAddress,
+			ChangeAddress: aliceAddress,
+		})
+	}
+
+	p, err := build(100000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := p.Data.UnsignedTx.Outputs
+	fee := outputValue(t, outputs[len(outputs)-1])
+
+	// the utxo covers the amount and the fee exactly, no change is left
+	p, err = build(60000000 + fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs = p.Data.UnsignedTx.Outputs
+	if len(outputs) != 2 {
+		t.Fatalf("Got %d outputs, expected 2", len(outputs))
+	}
+	if gotFee := outputValue(t, outputs[1]); gotFee != fee {
+		t.Fatalf("Got fee %d, expected %d", gotFee, fee)
+	}
+
+	if err := p.SignWithPrivateKey(0, alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FinalizeAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Extract(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type mockExplorer struct {
+	utxos []explorer.Utxo
+}
+
+func (e *mockExplorer) Ping() int { return 200 }
+
+func (e *mockExplorer) GetUnspents(address string) ([]explorer.Utxo, error) {
+	return e.utxos, nil
+}
+
+func (e *mockExplorer) GetTransaction(hash string) (explorer.Transaction, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *mockExplorer) GetTransactionHex(hash string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (e *mockExplorer) Broadcast(tx string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (e *mockExplorer) EstimateFees() (explorer.Estimation, error) {
+	return mockEstimation{}, nil
+}
+
+type mockEstimation struct{}
+
+func (mockEstimation) Low() float64    { return 0.1 }
+func (mockEstimation) Medium() float64 { return 0.1 }
+func (mockEstimation) High() float64   { return 0.1 }
+
+func outputValue(t *testing.T, output *transaction.TxOutput) uint64 {
+	t.Helper()
+	var value [confidential.ElementsUnconfidentialValueLength]byte
+	copy(value[:], output.Value)
+	satoshi, err := confidential.ElementsToSatoshiValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return satoshi
+}
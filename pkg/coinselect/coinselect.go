@@ -1,12 +1,12 @@
 package coinselect
 
 import (
-	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
 
-	"github.com/tiero/ocean/internal/bufferutil"
 	"github.com/tiero/ocean/pkg/explorer"
-	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/tiero/ocean/pkg/unblinding"
 )
 
 // Coins defines the struct thta holds utxos and relative blinding keys.
@@ -14,83 +14,176 @@ type Coins struct {
 	Utxos        []explorer.Utxo
 	BlindingKey  []byte
 	BlindingKeys [][]byte
+	// Unblinder, if defined, is used instead of BlindingKey and BlindingKeys
+	// to unblind confidential utxos
+	Unblinder *unblinding.Unblinder
+	// Strategy is the algorithm used to select coins, FirstFit by default
+	Strategy Strategy
+	// FallbackStrategy is used if BranchAndBound finds no changeless match
+	FallbackStrategy Strategy
+	// CostOfChange is the max excess over the target amount BranchAndBound
+	// accepts for a changeless match of FeeAsset. The excess is returned apart
+	// from the change, to be paid as fee. Other assets can not be paid as fee,
+	// thus only their exact matches are accepted
+	CostOfChange uint64
+	// FeeAsset is the asset paying fees, required if CostOfChange is set
+	FeeAsset string
 }
 
-// CoinSelect returns the utxos that satisfies the target amount and target asset.
-// TODO implement branch and bound algorithm.
-func (cs *Coins) CoinSelect(amount uint64, asset string) (unspents []explorer.Utxo, change uint64, err error) {
-	change = 0
-	unspents = []explorer.Utxo{}
-	availableSats := uint64(0)
+// InsufficientFundsError is returned when the utxos do not cover the target
+// amount of an asset
+type InsufficientFundsError struct {
+	Asset     string
+	Amount    uint64
+	Available uint64
+}
 
-	for index, unspent := range cs.Utxos {
-		u := unspent
-		assetHash := u.Asset()
-		amountSatoshis := u.Value()
-		if len(u.AssetCommitment()) > 0 && len(u.ValueCommitment()) > 0 {
-			bk := cs.BlindingKey
-			if len(cs.BlindingKeys) > 0 {
-				bk = cs.BlindingKeys[index]
-			}
-			av, err := unblindUxto(u, bk)
-			if err != nil {
-				return nil, 0, err
-			}
-			assetHash = av.asset
-			amountSatoshis = av.value
-		}
-		if asset == assetHash {
-			unspents = append(unspents, unspent)
-			availableSats += amountSatoshis
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf(
+		"You do not have enough coins of asset %s: needed %d, available %d",
+		e.Asset, e.Amount, e.Available,
+	)
+}
 
-			if availableSats >= amount {
-				break
-			}
-		}
+// CoinSelect returns the utxos that satisfies the target amount and target asset,
+// selected with the configured strategy. The selected amount is the target
+// plus the change plus the excess of a changeless BranchAndBound match of the
+// fee asset, to be paid as fee.
+func (cs *Coins) CoinSelect(amount uint64, asset string) (unspents []explorer.Utxo, change uint64, excess uint64, err error) {
+	coins, err := cs.unblindedCoins()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	selected, change, excess, err := cs.selectCoins(coins, amount, asset)
+	if err != nil {
+		return nil, 0, 0, err
 	}
+	return utxosOf(selected), change, excess, nil
+}
 
-	if availableSats < amount {
-		return nil, 0, errors.New("You do not have enough coins")
+// CoinSelectMany returns the utxos that satisfy the target amounts of all the
+// given assets, with the change of each asset and the excess of a changeless
+// BranchAndBound match of the fee asset, to be paid as fee. Utxos are
+// selected at most once. If the utxos of an asset are not enough an
+// *InsufficientFundsError is returned.
+func (cs *Coins) CoinSelectMany(targets map[string]uint64) (unspents []explorer.Utxo, changes map[string]uint64, excess uint64, err error) {
+	coins, err := cs.unblindedCoins()
+	if err != nil {
+		return nil, nil, 0, err
 	}
 
-	change = availableSats - amount
+	assets := make([]string, 0, len(targets))
+	for asset := range targets {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
 
-	return unspents, change, nil
+	unspents = []explorer.Utxo{}
+	changes = make(map[string]uint64, len(targets))
+	for _, asset := range assets {
+		if targets[asset] == 0 {
+			changes[asset] = 0
+			continue
+		}
+		selected, change, assetExcess, err := cs.selectCoins(coins, targets[asset], asset)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		unspents = append(unspents, utxosOf(selected)...)
+		changes[asset] = change
+		excess += assetExcess
+	}
+	return unspents, changes, excess, nil
 }
 
-type assetAndValue struct {
+// coin defines an utxo with its unblinded asset and value. In fee aware
+// selection, value is the effective one, net of the fee to spend the utxo
+type coin struct {
+	utxo  explorer.Utxo
 	asset string
 	value uint64
+	fee   uint64
 }
 
-func unblindUxto(prevout explorer.Utxo, blindingKey []byte) (*assetAndValue, error) {
-	assetCommitment, err := hex.DecodeString(prevout.AssetCommitment())
-	if err != nil {
-		return nil, err
+// unblindedCoins returns the asset and value of every utxo, unblinding the
+// confidential ones. Utxos listed more than once are returned only once.
+func (cs *Coins) unblindedCoins() ([]coin, error) {
+	if len(cs.BlindingKeys) > 0 && len(cs.BlindingKeys) != len(cs.Utxos) {
+		return nil, fmt.Errorf(
+			"got %d blinding keys for %d utxos", len(cs.BlindingKeys), len(cs.Utxos),
+		)
 	}
-	valueCommitment, err := hex.DecodeString(prevout.ValueCommitment())
-	if err != nil {
-		return nil, err
+
+	coins := make([]coin, 0, len(cs.Utxos))
+	seen := make(map[string]bool)
+	for index, u := range cs.Utxos {
+		outpoint := fmt.Sprintf("%s:%d", u.Hash(), u.Index())
+		if seen[outpoint] {
+			continue
+		}
+		seen[outpoint] = true
+
+		var unblinded *unblinding.UnblindedUtxo
+		var err error
+		switch {
+		case cs.Unblinder != nil:
+			unblinded, err = cs.Unblinder.Unblind(u)
+		case len(cs.BlindingKeys) > 0:
+			unblinded, err = unblinding.UnblindWithKey(u, cs.BlindingKeys[index])
+		default:
+			unblinded, err = unblinding.UnblindWithKey(u, cs.BlindingKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		coins = append(coins, coin{utxo: u, asset: unblinded.Asset, value: unblinded.Value})
+	}
+	return coins, nil
+}
+
+// selectCoins returns the coins of the given asset that satisfy the target
+// amount, chosen with the configured strategy, the change and the excess of a
+// changeless BranchAndBound match, to be paid as fee. The cost of change is
+// applied to the fee asset only, so that other assets never have an excess
+func (cs *Coins) selectCoins(coins []coin, amount uint64, asset string) ([]coin, uint64, uint64, error) {
+	if cs.CostOfChange > 0 && cs.FeeAsset == "" {
+		return nil, 0, 0, errors.New("fee asset is required to use cost of change")
+	}
+	costOfChange := uint64(0)
+	if asset == cs.FeeAsset {
+		costOfChange = cs.CostOfChange
+	}
+
+	candidates := []coin{}
+	availableSats := uint64(0)
+	for _, c := range coins {
+		if c.asset == asset {
+			candidates = append(candidates, c)
+			availableSats += c.value
+		}
+	}
+
+	if availableSats < amount {
+		return nil, 0, 0, &InsufficientFundsError{asset, amount, availableSats}
 	}
-	nonce, err := confidential.NonceHash(
-		prevout.Nonce(),
-		blindingKey,
+
+	selected, changeless := selectWithStrategy(
+		cs.Strategy, cs.FallbackStrategy, candidates, amount, costOfChange,
 	)
-	if err != nil {
-		return nil, err
+	selectedSats := uint64(0)
+	for _, c := range selected {
+		selectedSats += c.value
 	}
-	unblindOutputArg := confidential.UnblindOutputArg{
-		Nonce:           nonce,
-		Rangeproof:      prevout.RangeProof(),
-		ValueCommitment: valueCommitment,
-		AssetCommitment: assetCommitment,
-		ScriptPubkey:    prevout.Script(),
+	if changeless {
+		return selected, 0, selectedSats - amount, nil
 	}
+	return selected, selectedSats - amount, 0, nil
+}
 
-	output, err := confidential.UnblindOutput(unblindOutputArg)
-	if err != nil {
-		return nil, err
+func utxosOf(coins []coin) []explorer.Utxo {
+	utxos := make([]explorer.Utxo, 0, len(coins))
+	for _, c := range coins {
+		utxos = append(utxos, c.utxo)
 	}
-	assetHash := hex.EncodeToString(bufferutil.ReverseBytes(output.Asset[:]))
-	return &assetAndValue{assetHash, output.Value}, nil
+	return utxos
 }
@@ -1,6 +1,8 @@
 package coinselect
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/tiero/ocean/pkg/explorer"
@@ -62,7 +64,7 @@ func TestCoinSelect(t *testing.T) {
 
 	coins := &Coins{Utxos: []explorer.Utxo{testUtxo1, testUtxo2}}
 
-	gotUnspents, gotChange, err := coins.CoinSelect(800, "dollar")
+	gotUnspents, gotChange, _, err := coins.CoinSelect(800, "dollar")
 	if err != nil {
 		t.Errorf("Should not have throwed any error")
 	}
@@ -73,3 +75,232 @@ func TestCoinSelect(t *testing.T) {
 		t.Errorf("CoinSelect() gotChange")
 	}
 }
+
+func TestCoinSelectMany(t *testing.T) {
+	testUtxo1 := &utxo{"foo", 0, 1000, "dollar", "", ""}
+	testUtxo2 := &utxo{"bar", 0, 500, "euro", "", ""}
+	testUtxo3 := &utxo{"baz", 1, 700, "euro", "", ""}
+
+	coins := &Coins{Utxos: []explorer.Utxo{testUtxo1, testUtxo2, testUtxo3, testUtxo2}}
+
+	gotUnspents, gotChanges, _, err := coins.CoinSelectMany(map[string]uint64{
+		"dollar": 800,
+		"euro":   1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotUnspents) != 3 {
+		t.Errorf("CoinSelectMany() got %d unspents, expected 3", len(gotUnspents))
+	}
+	if gotChanges["dollar"] != 200 || gotChanges["euro"] != 200 {
+		t.Errorf("CoinSelectMany() gotChanges %v", gotChanges)
+	}
+
+	_, _, _, err = coins.CoinSelectMany(map[string]uint64{
+		"dollar": 800,
+		"euro":   1500,
+	})
+	var insufficientFunds *InsufficientFundsError
+	if !errors.As(err, &insufficientFunds) {
+		t.Fatalf("Should have failed with InsufficientFundsError, got %v", err)
+	}
+	if insufficientFunds.Asset != "euro" || insufficientFunds.Available != 1200 {
+		t.Errorf("CoinSelectMany() got error %v", insufficientFunds)
+	}
+}
+
+func TestCoinSelectStrategies(t *testing.T) {
+	utxos := []explorer.Utxo{
+		&utxo{"a", 0, 300, "dollar", "", ""},
+		&utxo{"b", 0, 1000, "dollar", "", ""},
+		&utxo{"c", 0, 200, "dollar", "", ""},
+		&utxo{"d", 0, 550, "dollar", "", ""},
+	}
+
+	tests := []struct {
+		name         string
+		strategy     Strategy
+		fallback     Strategy
+		costOfChange uint64
+		amount       uint64
+		wantHashes   []string
+		wantChange   uint64
+		wantExcess   uint64
+	}{
+		{"first fit", FirstFit, FirstFit, 0, 1100, []string{"a", "b"}, 200, 0},
+		{"largest first", LargestFirst, FirstFit, 0, 1100, []string{"b", "d"}, 450, 0},
+		{"smallest first", SmallestFirst, FirstFit, 0, 1000, []string{"c", "a", "d"}, 50, 0},
+		{"branch and bound exact match", BranchAndBound, FirstFit, 0, 1050, []string{"d", "a", "c"}, 0, 0},
+		{"branch and bound within cost of change", BranchAndBound, FirstFit, 60, 1240, []string{"b", "a"}, 0, 60},
+		{"branch and bound fallback", BranchAndBound, LargestFirst, 0, 1120, []string{"b", "d"}, 430, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coins := &Coins{
+				Utxos:            utxos,
+				Strategy:         tt.strategy,
+				FallbackStrategy: tt.fallback,
+				CostOfChange:     tt.costOfChange,
+				FeeAsset:         "dollar",
+			}
+			gotUnspents, gotChange, gotExcess, err := coins.CoinSelect(tt.amount, "dollar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotHashes := []string{}
+			for _, u := range gotUnspents {
+				gotHashes = append(gotHashes, u.Hash())
+			}
+			if !reflect.DeepEqual(gotHashes, tt.wantHashes) {
+				t.Errorf("CoinSelect() got %v, expected %v", gotHashes, tt.wantHashes)
+			}
+			if gotChange != tt.wantChange {
+				t.Errorf("CoinSelect() got change %d, expected %d", gotChange, tt.wantChange)
+			}
+			if gotExcess != tt.wantExcess {
+				t.Errorf("CoinSelect() got excess %d, expected %d", gotExcess, tt.wantExcess)
+			}
+		})
+	}
+}
+
+func TestCoinSelectRandom(t *testing.T) {
+	utxos := []explorer.Utxo{
+		&utxo{"a", 0, 300, "dollar", "", ""},
+		&utxo{"b", 0, 1000, "dollar", "", ""},
+		&utxo{"c", 0, 200, "dollar", "", ""},
+	}
+	coins := &Coins{Utxos: utxos, Strategy: Random}
+
+	gotUnspents, gotChange, _, err := coins.CoinSelect(1200, "dollar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := uint64(0)
+	for _, u := range gotUnspents {
+		total += u.Value()
+	}
+	if total < 1200 || total-1200 != gotChange {
+		t.Errorf("CoinSelect() got total %d and change %d", total, gotChange)
+	}
+}
+
+func TestCoinSelectWithFee(t *testing.T) {
+	utxos := []explorer.Utxo{
+		&utxo{"a", 0, 1000, "dollar", "", ""},
+		&utxo{"b", 0, 50, "dollar", "", ""},
+		&utxo{"c", 0, 500, "dollar", "", ""},
+	}
+	coins := &Coins{Utxos: utxos}
+
+	// spending each p2wpkh utxo costs 69 vbytes
+	gotUnspents, gotChange, gotFee, err := coins.CoinSelectWithFee(1200, "dollar", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotUnspents) != 2 || gotUnspents[1].Hash() != "c" {
+		t.Errorf("CoinSelectWithFee() should have skipped dust utxo")
+	}
+	if gotFee != 138 {
+		t.Errorf("CoinSelectWithFee() got fee %d, expected 138", gotFee)
+	}
+	if gotChange != 162 {
+		t.Errorf("CoinSelectWithFee() got change %d, expected 162", gotChange)
+	}
+
+	// the excess of a changeless match is paid as fee
+	coins.Strategy = BranchAndBound
+	coins.CostOfChange = 100
+	coins.FeeAsset = "dollar"
+	gotUnspents, gotChange, gotFee, err = coins.CoinSelectWithFee(1350, "dollar", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotUnspents) != 2 {
+		t.Errorf("CoinSelectWithFee() got %d unspents, expected 2", len(gotUnspents))
+	}
+	if gotChange != 0 {
+		t.Errorf("CoinSelectWithFee() got change %d, expected 0", gotChange)
+	}
+	if gotFee != 150 {
+		t.Errorf("CoinSelectWithFee() got fee %d, expected 150", gotFee)
+	}
+
+	_, _, _, err = coins.CoinSelectWithFee(1400, "dollar", 1)
+	var insufficientFunds *InsufficientFundsError
+	if !errors.As(err, &insufficientFunds) || insufficientFunds.Available != 1362 {
+		t.Errorf("Should have failed with InsufficientFundsError, got %v", err)
+	}
+}
+
+func TestInputVirtualSize(t *testing.T) {
+	tests := []struct {
+		name string
+		utxo explorer.Utxo
+		want float64
+	}{
+		{"explicit", &utxo{"a", 0, 1000, "dollar", "", ""}, 69},
+		// surjection proof bytes are witness data, a quarter of 32 bytes
+		{"confidential", &utxo{"b", 0, 1000, "dollar", "08aa", "0aaa"}, 77},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inputVirtualSize(tt.utxo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("inputVirtualSize() got %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoinSelectBlindingKeysMismatch(t *testing.T) {
+	coins := &Coins{
+		Utxos:        []explorer.Utxo{&utxo{"foo", 0, 1000, "dollar", "", ""}},
+		BlindingKeys: [][]byte{{0x01}, {0x02}},
+	}
+	if _, _, _, err := coins.CoinSelect(800, "dollar"); err == nil {
+		t.Fatal("Should have failed with mismatching blinding keys")
+	}
+}
+
+func TestCoinSelectCostOfChange(t *testing.T) {
+	utxos := []explorer.Utxo{
+		&utxo{"a", 0, 300, "dollar", "", ""},
+		&utxo{"b", 0, 1000, "dollar", "", ""},
+		&utxo{"c", 0, 300, "euro", "", ""},
+		&utxo{"d", 0, 1000, "euro", "", ""},
+	}
+	coins := &Coins{
+		Utxos:            utxos,
+		Strategy:         BranchAndBound,
+		FallbackStrategy: FirstFit,
+		CostOfChange:     60,
+	}
+
+	if _, _, _, err := coins.CoinSelect(1240, "dollar"); err == nil {
+		t.Fatal("Should have failed with cost of change and no fee asset")
+	}
+
+	// the excess is accepted only for the fee asset, the other assets fall
+	// back to a selection with change
+	coins.FeeAsset = "dollar"
+	_, gotChanges, gotExcess, err := coins.CoinSelectMany(map[string]uint64{
+		"dollar": 1240,
+		"euro":   1240,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotChanges["dollar"] != 0 || gotChanges["euro"] != 60 {
+		t.Errorf("CoinSelectMany() got changes %v", gotChanges)
+	}
+	if gotExcess != 60 {
+		t.Errorf("CoinSelectMany() got excess %d, expected 60", gotExcess)
+	}
+}
@@ -0,0 +1,108 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/tiero/ocean/pkg/explorer"
+)
+
+const (
+	// inputBaseSize is the size of outpoint, sequence and script sig length
+	inputBaseSize = 41
+	// inputWitnessBaseSize is the size of the witness fields of an elements
+	// input, issuance and inflation range proofs lengths, script witness and
+	// pegin witness counts
+	inputWitnessBaseSize = 4
+	// sigSize is the maximum size of a DER signature plus the sighash byte
+	sigSize = 73
+	// pubKeySize is the size of a compressed public key
+	pubKeySize = 33
+	// surjectionInputSize is the size added to the surjection proof of a
+	// blinded output by each input, spending confidential utxos requires at
+	// least one blinded output. Surjection proofs are witness data
+	surjectionInputSize = 32
+)
+
+// CoinSelectWithFee returns the utxos of the target asset that satisfy the
+// target amount plus the fee to spend them at the given rate in satoshi per
+// virtual byte. The asset must be the one paying for fees. Coins are selected
+// by their effective value, that is value minus spending cost, and the ones
+// costing more than their value are skipped as dust. The change and the fee
+// paid for the selected inputs, plus the excess of a changeless
+// BranchAndBound match, are returned as well.
+func (cs *Coins) CoinSelectWithFee(amount uint64, asset string, satPerVByte float64) (unspents []explorer.Utxo, change uint64, fee uint64, err error) {
+	if satPerVByte <= 0 {
+		return nil, 0, 0, errors.New("fee rate must be greater than 0")
+	}
+
+	coins, err := cs.unblindedCoins()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	effectiveCoins := make([]coin, 0, len(coins))
+	for _, c := range coins {
+		if c.asset != asset {
+			continue
+		}
+		cost, err := spendingCost(c.utxo, satPerVByte)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("utxo %s:%d: %w", c.utxo.Hash(), c.utxo.Index(), err)
+		}
+		if cost >= c.value {
+			continue
+		}
+		c.value -= cost
+		c.fee = cost
+		effectiveCoins = append(effectiveCoins, c)
+	}
+
+	selected, change, excess, err := cs.selectCoins(effectiveCoins, amount, asset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	fee = excess
+	for _, c := range selected {
+		fee += c.fee
+	}
+	return utxosOf(selected), change, fee, nil
+}
+
+// spendingCost returns the fee to spend the given utxo at the given rate
+func spendingCost(utxo explorer.Utxo, satPerVByte float64) (uint64, error) {
+	size, err := inputVirtualSize(utxo)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(math.Ceil(size * satPerVByte)), nil
+}
+
+// inputVirtualSize returns the estimated virtual size of the signed input
+// spending the given utxo. Utxos without script, as returned by some
+// explorers for unconfidential ones, are expected to be p2wpkh
+func inputVirtualSize(utxo explorer.Utxo) (float64, error) {
+	script := utxo.Script()
+	scriptSigSize, witnessSize := 0, inputWitnessBaseSize
+
+	switch {
+	case len(script) == 0 || txscript.IsPayToWitnessPubKeyHash(script):
+		witnessSize += 1 + sigSize + 1 + pubKeySize
+	case txscript.IsPayToScriptHash(script):
+		// p2sh utxos are expected to wrap p2wpkh
+		scriptSigSize = 1 + 22
+		witnessSize += 1 + sigSize + 1 + pubKeySize
+	case txscript.GetScriptClass(script) == txscript.PubKeyHashTy:
+		scriptSigSize = 1 + sigSize + 1 + pubKeySize
+	default:
+		return 0, errors.New("unable to estimate size of unsupported script type")
+	}
+
+	if len(utxo.AssetCommitment()) > 0 && len(utxo.ValueCommitment()) > 0 {
+		witnessSize += surjectionInputSize
+	}
+	// witness data is discounted by the segwit scale factor
+	return float64(inputBaseSize+scriptSigSize) + float64(witnessSize)/4, nil
+}
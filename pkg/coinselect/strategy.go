@@ -0,0 +1,155 @@
+package coinselect
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Strategy defines the algorithm used to select the coins of an asset
+type Strategy int
+
+const (
+	// FirstFit selects coins in list order until the target is reached
+	FirstFit Strategy = iota
+	// LargestFirst selects the coins with greater value first, minimizing the
+	// number of inputs
+	LargestFirst
+	// SmallestFirst selects the coins with lower value first, consolidating
+	// small utxos
+	SmallestFirst
+	// Random selects coins in random order
+	Random
+	// BranchAndBound searches for a set of coins matching the target without
+	// change, that is exceeding it by at most the cost of change. The excess
+	// of a match is paid as fee instead of going to a change output. If no
+	// match is found, the fallback strategy is used instead
+	BranchAndBound
+)
+
+// maxBranchAndBoundTries is the max number of branches explored before
+// giving up the search for a changeless match
+const maxBranchAndBoundTries = 100000
+
+// selectWithStrategy returns the coins that satisfy the target amount and
+// whether they are a changeless BranchAndBound match. All the given coins must
+// be of the same asset and cover the amount
+func selectWithStrategy(strategy, fallback Strategy, coins []coin, amount, costOfChange uint64) ([]coin, bool) {
+	switch strategy {
+	case LargestFirst:
+		sorted := copyCoins(coins)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].value > sorted[j].value
+		})
+		return accumulate(sorted, amount), false
+	case SmallestFirst:
+		sorted := copyCoins(coins)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].value < sorted[j].value
+		})
+		return accumulate(sorted, amount), false
+	case Random:
+		shuffled := copyCoins(coins)
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		random.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return accumulate(shuffled, amount), false
+	case BranchAndBound:
+		if selected, ok := branchAndBound(coins, amount, costOfChange); ok {
+			return selected, true
+		}
+		if fallback == BranchAndBound {
+			fallback = FirstFit
+		}
+		return selectWithStrategy(fallback, FirstFit, coins, amount, costOfChange)
+	default:
+		return accumulate(coins, amount), false
+	}
+}
+
+// accumulate returns the coins, taken in the given order, until their total
+// value reaches the amount
+func accumulate(coins []coin, amount uint64) []coin {
+	selected := []coin{}
+	availableSats := uint64(0)
+	for _, c := range coins {
+		selected = append(selected, c)
+		availableSats += c.value
+		if availableSats >= amount {
+			break
+		}
+	}
+	return selected
+}
+
+// branchAndBound performs a depth first search, over the coins sorted by
+// descending value, for the set whose total value is within the range
+// [amount, amount+costOfChange], preferring the one with the lowest excess.
+// Branches are pruned as soon as they exceed the range or can not reach the
+// amount anymore
+func branchAndBound(coins []coin, amount, costOfChange uint64) ([]coin, bool) {
+	sorted := copyCoins(coins)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].value > sorted[j].value
+	})
+
+	// remaining[i] is the total value of the coins from index i onwards
+	remaining := make([]uint64, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].value
+	}
+
+	var (
+		best      []int
+		bestTotal uint64
+		found     bool
+		tries     int
+	)
+	current := make([]int, 0, len(sorted))
+
+	var search func(index int, total uint64) bool
+	search = func(index int, total uint64) bool {
+		tries++
+		if tries > maxBranchAndBoundTries {
+			return true
+		}
+		if total > amount+costOfChange || total+remaining[index] < amount {
+			return false
+		}
+		if total >= amount {
+			if !found || total < bestTotal {
+				best = append([]int{}, current...)
+				bestTotal = total
+				found = true
+			}
+			// an exact match can not be improved
+			return total == amount
+		}
+		if index == len(sorted) {
+			return false
+		}
+
+		current = append(current, index)
+		if search(index+1, total+sorted[index].value) {
+			return true
+		}
+		current = current[:len(current)-1]
+		return search(index+1, total)
+	}
+	search(0, 0)
+
+	if !found {
+		return nil, false
+	}
+	selected := make([]coin, 0, len(best))
+	for _, i := range best {
+		selected = append(selected, sorted[i])
+	}
+	return selected, true
+}
+
+// copyCoins returns a copy of the given coins, to be sorted or shuffled
+func copyCoins(coins []coin) []coin {
+	return append([]coin{}, coins...)
+}
@@ -0,0 +1,100 @@
+package confidential
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil/base58"
+	addressPackage "github.com/vulpemventures/go-elements/address"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+// prefixPlusBlindKeySize is the size of the address type prefix and of the
+// blinding key of base58 confidential addresses
+const prefixPlusBlindKeySize = 34
+
+// FromScript returns the confidential address of the given output script,
+// either p2pkh, p2sh, p2wpkh or p2wsh, embedding the given blinding public key
+func FromScript(script []byte, blindingKey []byte, net network.Network) (string, error) {
+	if len(blindingKey) != 33 {
+		return "", errors.New("blinding key must be a compressed public key")
+	}
+	if _, err := btcec.ParsePubKey(blindingKey, btcec.S256()); err != nil {
+		return "", err
+	}
+
+	switch {
+	case txscript.GetScriptClass(script) == txscript.PubKeyHashTy:
+		return toBase58Confidential(net.PubKeyHash, blindingKey, script[3:23], net), nil
+	case txscript.IsPayToScriptHash(script):
+		return toBase58Confidential(net.ScriptHash, blindingKey, script[2:22], net), nil
+	case txscript.IsPayToWitnessPubKeyHash(script), txscript.IsPayToWitnessScriptHash(script):
+		return addressPackage.ToBlech32(&addressPackage.Blech32{
+			Prefix:    net.Blech32,
+			Version:   0,
+			PublicKey: blindingKey,
+			Program:   script[2:],
+		})
+	default:
+		return "", errors.New("unsupported script type")
+	}
+}
+
+// FromAddress returns the confidential address of the given unconfidential
+// address, embedding the given blinding public key
+func FromAddress(address string, blindingKey []byte, net network.Network) (string, error) {
+	decoded, err := Decode(address, net)
+	if err != nil {
+		return "", err
+	}
+	if decoded.IsConfidential() {
+		return "", errors.New("address is already confidential")
+	}
+	return FromScript(decoded.Script, blindingKey, net)
+}
+
+// ToUnconfidential returns the unconfidential address of the given
+// confidential one. Unconfidential addresses are returned as they are
+func ToUnconfidential(address string, net network.Network) (string, error) {
+	decoded, err := Decode(address, net)
+	if err != nil {
+		return "", err
+	}
+
+	switch decoded.Type {
+	case ConfidentialP2Pkh:
+		return addressPackage.ToBase58(&addressPackage.Base58{
+			Version: net.PubKeyHash,
+			Data:    decoded.Program,
+		}), nil
+	case ConfidentialP2Sh:
+		return addressPackage.ToBase58(&addressPackage.Base58{
+			Version: net.ScriptHash,
+			Data:    decoded.Program,
+		}), nil
+	case ConfidentialP2Wpkh, ConfidentialP2Wsh:
+		return addressPackage.ToBech32(&addressPackage.Bech32{
+			Prefix:  net.Bech32,
+			Version: byte(decoded.WitnessVersion),
+			Program: decoded.Program,
+		})
+	default:
+		return address, nil
+	}
+}
+
+// IsConfidential returns whether the given address is confidential
+func IsConfidential(address string, net network.Network) (bool, error) {
+	decoded, err := Decode(address, net)
+	if err != nil {
+		return false, err
+	}
+	return decoded.IsConfidential(), nil
+}
+
+func toBase58Confidential(prefix byte, blindingKey, hash []byte, net network.Network) string {
+	payload := append([]byte{prefix}, blindingKey...)
+	payload = append(payload, hash...)
+	return base58.CheckEncode(payload, net.Confidential)
+}
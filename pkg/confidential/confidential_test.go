@@ -1,10 +1,13 @@
 package confidential
 
 import (
+	"bytes"
 	"encoding/hex"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/payment"
 )
 
 func TestToBlindingKey(t *testing.T) {
@@ -17,3 +20,208 @@ func TestToBlindingKey(t *testing.T) {
 		t.Fatal("Not the right blinding key")
 	}
 }
+
+func TestConfidentialAddress(t *testing.T) {
+	privateKey, _ := hex.DecodeString("bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86")
+	blindingPrivateKey, _ := hex.DecodeString("dd65e215154c13b1c14f9dc0aa7cfc1f40414f214bd0c5dfe2d370880bdf8356")
+	_, publicKey := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	_, blindingKey := btcec.PrivKeyFromBytes(btcec.S256(), blindingPrivateKey)
+
+	p2pkh := payment.FromPublicKey(publicKey, &network.Regtest, blindingKey)
+	p2sh, err := payment.FromPayment(p2pkh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type addressFunc func() (string, error)
+	tests := []struct {
+		name           string
+		script         []byte
+		unconfidential addressFunc
+		confidential   addressFunc
+	}{
+		{"p2pkh", p2pkh.Script, p2pkh.PubKeyHash, p2pkh.ConfidentialPubKeyHash},
+		{"p2sh", p2sh.Script, p2sh.ScriptHash, p2sh.ConfidentialScriptHash},
+		{"p2wpkh", p2pkh.WitnessScript, p2pkh.WitnessPubKeyHash, p2pkh.ConfidentialWitnessPubKeyHash},
+		{"p2wsh", p2sh.WitnessScript, p2sh.WitnessScriptHash, p2sh.ConfidentialWitnessScriptHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unconfidential, _ := tt.unconfidential()
+			want, _ := tt.confidential()
+
+			got, err := FromScript(tt.script, blindingKey.SerializeCompressed(), network.Regtest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("Got address %s, expected %s", got, want)
+			}
+			if got, err = FromAddress(unconfidential, blindingKey.SerializeCompressed(), network.Regtest); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("Got address %s, expected %s", got, want)
+			}
+
+			gotUnconfidential, err := ToUnconfidential(want, network.Regtest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotUnconfidential != unconfidential {
+				t.Fatalf("Got unconfidential address %s, expected %s", gotUnconfidential, unconfidential)
+			}
+
+			for address, wantConfidential := range map[string]bool{want: true, unconfidential: false} {
+				isConfidential, err := IsConfidential(address, network.Regtest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if isConfidential != wantConfidential {
+					t.Fatalf("%s: got confidential %v, expected %v", address, isConfidential, wantConfidential)
+				}
+			}
+
+			if _, err := FromAddress(want, blindingKey.SerializeCompressed(), network.Regtest); err == nil {
+				t.Fatal("Should have failed with confidential address")
+			}
+		})
+	}
+
+	if _, err := FromScript(p2pkh.Script, []byte{0x02}, network.Regtest); err == nil {
+		t.Fatal("Should have failed with invalid blinding key")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	privateKey, _ := hex.DecodeString("bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86")
+	blindingPrivateKey, _ := hex.DecodeString("dd65e215154c13b1c14f9dc0aa7cfc1f40414f214bd0c5dfe2d370880bdf8356")
+	_, publicKey := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	_, blindingKey := btcec.PrivKeyFromBytes(btcec.S256(), blindingPrivateKey)
+
+	p2pkh := payment.FromPublicKey(publicKey, &network.Regtest, blindingKey)
+	p2sh, err := payment.FromPayment(p2pkh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type addressFunc func() (string, error)
+	tests := []struct {
+		name             string
+		address          addressFunc
+		wantType         int
+		wantVersion      int
+		wantProgram      []byte
+		wantScript       []byte
+		wantConfidential bool
+	}{
+		{"p2pkh", p2pkh.PubKeyHash, P2Pkh, -1, p2pkh.Hash, p2pkh.Script, false},
+		{"confidential p2pkh", p2pkh.ConfidentialPubKeyHash, ConfidentialP2Pkh, -1, p2pkh.Hash, p2pkh.Script, true},
+		{"p2sh", p2sh.ScriptHash, P2Sh, -1, p2sh.Hash, p2sh.Script, false},
+		{"confidential p2sh", p2sh.ConfidentialScriptHash, ConfidentialP2Sh, -1, p2sh.Hash, p2sh.Script, true},
+		{"p2wpkh", p2pkh.WitnessPubKeyHash, P2Wpkh, 0, p2pkh.WitnessHash, p2pkh.WitnessScript, false},
+		{"confidential p2wpkh", p2pkh.ConfidentialWitnessPubKeyHash, ConfidentialP2Wpkh, 0, p2pkh.WitnessHash, p2pkh.WitnessScript, true},
+		{"p2wsh", p2sh.WitnessScriptHash, P2Wsh, 0, p2sh.WitnessHash, p2sh.WitnessScript, false},
+		{"confidential p2wsh", p2sh.ConfidentialWitnessScriptHash, ConfidentialP2Wsh, 0, p2sh.WitnessHash, p2sh.WitnessScript, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := tt.address()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(address, network.Regtest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != tt.wantType {
+				t.Fatalf("Got type %d, expected %d", got.Type, tt.wantType)
+			}
+			if got.Network.Bech32 != network.Regtest.Bech32 {
+				t.Fatalf("Got network %s, expected %s", got.Network.Bech32, network.Regtest.Bech32)
+			}
+			if got.WitnessVersion != tt.wantVersion {
+				t.Fatalf("Got witness version %d, expected %d", got.WitnessVersion, tt.wantVersion)
+			}
+			if !bytes.Equal(got.Program, tt.wantProgram) {
+				t.Fatalf("Got program %x, expected %x", got.Program, tt.wantProgram)
+			}
+			if !bytes.Equal(got.Script, tt.wantScript) {
+				t.Fatalf("Got script %x, expected %x", got.Script, tt.wantScript)
+			}
+			wantBlindingKey := []byte(nil)
+			if tt.wantConfidential {
+				wantBlindingKey = blindingKey.SerializeCompressed()
+			}
+			if !bytes.Equal(got.BlindingKey, wantBlindingKey) {
+				t.Fatalf("Got blinding key %x, expected %x", got.BlindingKey, wantBlindingKey)
+			}
+			if got.IsConfidential() != tt.wantConfidential {
+				t.Fatalf("Got confidential %v, expected %v", got.IsConfidential(), tt.wantConfidential)
+			}
+		})
+	}
+
+	if _, err := Decode("AzpwTgRMptQ8CB1UTrc6ereqFt6ZDTwJSgm6iu2BHRZbrXEXyu8x2cjAkZR5BeVznVeiTCCqqsQKzcwD", network.Liquid); err == nil {
+		t.Fatal("Should have failed with address of another network")
+	}
+}
+
+func TestDetectNetwork(t *testing.T) {
+	privateKey, _ := hex.DecodeString("bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86")
+	blindingPrivateKey, _ := hex.DecodeString("dd65e215154c13b1c14f9dc0aa7cfc1f40414f214bd0c5dfe2d370880bdf8356")
+	_, publicKey := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	_, blindingKey := btcec.PrivKeyFromBytes(btcec.S256(), blindingPrivateKey)
+
+	for _, net := range Networks {
+		p2pkh := payment.FromPublicKey(publicKey, net, blindingKey)
+		p2sh, err := payment.FromPayment(p2pkh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addressFuncs := []func() (string, error){
+			p2pkh.PubKeyHash,
+			p2pkh.ConfidentialPubKeyHash,
+			p2sh.ScriptHash,
+			p2sh.ConfidentialScriptHash,
+			p2pkh.WitnessPubKeyHash,
+			p2pkh.ConfidentialWitnessPubKeyHash,
+			p2sh.WitnessScriptHash,
+			p2sh.ConfidentialWitnessScriptHash,
+		}
+
+		for _, addressFunc := range addressFuncs {
+			address, err := addressFunc()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Run(net.Name+"/"+address, func(t *testing.T) {
+				got, err := DetectNetwork(address)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Name != net.Name {
+					t.Fatalf("Got network %s, expected %s", got.Name, net.Name)
+				}
+
+				decoded, err := DecodeAnyNetwork(address)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := Decode(address, *net)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decoded.Type != want.Type || !bytes.Equal(decoded.Script, want.Script) {
+					t.Fatalf("Got address %+v, expected %+v", decoded, want)
+				}
+			})
+		}
+	}
+
+	if _, err := DetectNetwork("not an address"); err == nil {
+		t.Fatal("Should have failed with invalid address")
+	}
+}
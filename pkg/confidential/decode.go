@@ -0,0 +1,122 @@
+package confidential
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil/base58"
+	addressPackage "github.com/vulpemventures/go-elements/address"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+// Address defines a decoded address
+type Address struct {
+	// Type is one of P2Pkh, P2Sh, ConfidentialP2Pkh, ConfidentialP2Sh, P2Wpkh,
+	// P2Wsh, ConfidentialP2Wpkh and ConfidentialP2Wsh
+	Type    int
+	Network *network.Network
+	// WitnessVersion is the version of segwit addresses, -1 for base58 ones
+	WitnessVersion int
+	// Program is the witness program of segwit addresses or the public key
+	// or script hash of base58 ones
+	Program []byte
+	// BlindingKey is the blinding public key of confidential addresses
+	BlindingKey []byte
+	// Script is the output script paying to the address
+	Script []byte
+}
+
+// Decode returns the Address decoded from the given string for the given
+// network
+func Decode(address string, net network.Network) (*Address, error) {
+	addressType, err := addressPackage.DecodeType(address, net)
+	if err != nil {
+		if detected, _ := DetectNetwork(address); detected != nil && detected.Name != net.Name {
+			return nil, fmt.Errorf("address is for network %s, not %s", detected.Name, net.Name)
+		}
+		return nil, err
+	}
+
+	decoded := &Address{
+		Type:           addressType,
+		Network:        &net,
+		WitnessVersion: -1,
+	}
+	switch addressType {
+	case P2Pkh, P2Sh:
+		data, _, err := base58.CheckDecode(address)
+		if err != nil {
+			return nil, err
+		}
+		decoded.Program = data
+	case ConfidentialP2Pkh, ConfidentialP2Sh:
+		data, _, err := base58.CheckDecode(address)
+		if err != nil {
+			return nil, err
+		}
+		decoded.BlindingKey = data[1:prefixPlusBlindKeySize]
+		decoded.Program = data[prefixPlusBlindKeySize:]
+	case P2Wpkh, P2Wsh:
+		fromBech32, err := addressPackage.FromBech32(address)
+		if err != nil {
+			return nil, err
+		}
+		decoded.WitnessVersion = int(fromBech32.Version)
+		decoded.Program = fromBech32.Program
+	case ConfidentialP2Wpkh, ConfidentialP2Wsh:
+		fromBlech32, err := addressPackage.FromBlech32(address)
+		if err != nil {
+			return nil, err
+		}
+		decoded.WitnessVersion = int(fromBlech32.Version)
+		decoded.BlindingKey = fromBlech32.PublicKey
+		decoded.Program = fromBlech32.Program
+	default:
+		return nil, errors.New("unsupported address type")
+	}
+
+	if decoded.Script, err = decoded.outputScript(); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// IsConfidential returns whether the address is confidential
+func (a *Address) IsConfidential() bool {
+	return len(a.BlindingKey) > 0
+}
+
+// IsSegwit returns whether the address is a native segwit one
+func (a *Address) IsSegwit() bool {
+	return a.WitnessVersion >= 0
+}
+
+// outputScript returns the output script paying to the address
+func (a *Address) outputScript() ([]byte, error) {
+	switch a.Type {
+	case P2Pkh, ConfidentialP2Pkh:
+		return txscript.NewScriptBuilder().
+			AddOp(txscript.OP_DUP).
+			AddOp(txscript.OP_HASH160).
+			AddData(a.Program).
+			AddOp(txscript.OP_EQUALVERIFY).
+			AddOp(txscript.OP_CHECKSIG).
+			Script()
+	case P2Sh, ConfidentialP2Sh:
+		return txscript.NewScriptBuilder().
+			AddOp(txscript.OP_HASH160).
+			AddData(a.Program).
+			AddOp(txscript.OP_EQUAL).
+			Script()
+	default:
+		version := byte(txscript.OP_0)
+		if a.WitnessVersion > 0 {
+			version = byte(txscript.OP_1 + a.WitnessVersion - 1)
+		}
+		return txscript.NewScriptBuilder().
+			AddOp(version).
+			AddData(a.Program).
+			Script()
+	}
+}
@@ -0,0 +1,65 @@
+package confidential
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+// Testnet defines the Liquid testnet network, not yet provided by go-elements
+var Testnet = network.Network{
+	Name:         "testnet",
+	Bech32:       "tex",
+	Blech32:      "tlq",
+	HDPublicKey:  [4]byte{0x04, 0x35, 0x87, 0xcf},
+	HDPrivateKey: [4]byte{0x04, 0x35, 0x83, 0x94},
+	PubKeyHash:   36,
+	ScriptHash:   19,
+	Wif:          0xef,
+	Confidential: 23,
+	AssetID:      "144c654344aa716d6f3abcc1ca90e5641e4e2a7f633bc09fe3baf64585819a49",
+}
+
+// Networks are the networks addresses are detected among
+var Networks = []*network.Network{&network.Liquid, &Testnet, &network.Regtest}
+
+// DetectNetwork returns the network of the given address by inspecting the
+// blech32 and bech32 human readable part or, for base58 addresses, the
+// version prefixes
+func DetectNetwork(address string) (*network.Network, error) {
+	if oneIndex := strings.LastIndexByte(address, '1'); oneIndex > 1 {
+		hrp := strings.ToLower(address[:oneIndex])
+		for _, net := range Networks {
+			if hrp == net.Blech32 || hrp == net.Bech32 {
+				return net, nil
+			}
+		}
+	}
+
+	data, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, errors.New("unknown address format")
+	}
+	for _, net := range Networks {
+		if version == net.Confidential && len(data) > 0 &&
+			(data[0] == net.PubKeyHash || data[0] == net.ScriptHash) {
+			return net, nil
+		}
+		if version == net.PubKeyHash || version == net.ScriptHash {
+			return net, nil
+		}
+	}
+	return nil, errors.New("unknown address network")
+}
+
+// DecodeAnyNetwork returns the Address decoded from the given string for the
+// network detected with DetectNetwork
+func DecodeAnyNetwork(address string) (*Address, error) {
+	net, err := DetectNetwork(address)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(address, *net)
+}
@@ -0,0 +1,66 @@
+package keypair
+
+import (
+	"github.com/vulpemventures/go-elements/slip77"
+)
+
+// MasterBlindingKey derives SLIP-77 blinding keys deterministically from the
+// output scripts, so that the blinding key of any output of the wallet can be
+// recomputed instead of stored
+type MasterBlindingKey struct {
+	slip77 *slip77.Slip77
+}
+
+// NewMasterBlindingKey returns the SLIP-77 master blinding key of the given
+// seed, like the one returned by MnemonicToSeed
+func NewMasterBlindingKey(seed []byte) (*MasterBlindingKey, error) {
+	s, err := slip77.FromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	return &MasterBlindingKey{s}, nil
+}
+
+// FromMasterBlindingKey takes a serialized master blinding key and returns a
+// MasterBlindingKey instance
+func FromMasterBlindingKey(masterKey []byte) (*MasterBlindingKey, error) {
+	s, err := slip77.FromMasterKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &MasterBlindingKey{s}, nil
+}
+
+// Bytes returns the serialized master blinding key
+func (k *MasterBlindingKey) Bytes() []byte {
+	return k.slip77.MasterKey
+}
+
+// KeyPair returns the blinding key pair of the given output script
+func (k *MasterBlindingKey) KeyPair(script []byte) (*KeyPair, error) {
+	privateKey, publicKey, err := k.slip77.DeriveKey(script)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{publicKey, privateKey}, nil
+}
+
+// PrivateKey returns the serialized private blinding key of the given output
+// script. It can be used as blinding key lookup to unblind utxos
+func (k *MasterBlindingKey) PrivateKey(script []byte) ([]byte, error) {
+	keyPair, err := k.KeyPair(script)
+	if err != nil {
+		return nil, err
+	}
+	return keyPair.PrivateKey.Serialize(), nil
+}
+
+// PublicKey returns the compressed public blinding key of the given output
+// script. It can be used as blinding key lookup to blind outputs
+func (k *MasterBlindingKey) PublicKey(script []byte) ([]byte, error) {
+	keyPair, err := k.KeyPair(script)
+	if err != nil {
+		return nil, err
+	}
+	return keyPair.PublicKey.SerializeCompressed(), nil
+}
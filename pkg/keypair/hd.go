@@ -0,0 +1,162 @@
+package keypair
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+// HDKey defines a BIP32 extended key. Keys derived from an extended public
+// key are public only and can be used for watch-only wallets
+type HDKey struct {
+	key     *hdkeychain.ExtendedKey
+	network *network.Network
+}
+
+// NewMasterKey returns the master extended private key for the given seed,
+// serialized with the version bytes of the given network
+func NewMasterKey(seed []byte, net *network.Network) (*HDKey, error) {
+	currentNetwork := networkOrDefault(net)
+	key, err := hdkeychain.NewMaster(seed, chainParams(currentNetwork))
+	if err != nil {
+		return nil, err
+	}
+	return &HDKey{key, currentNetwork}, nil
+}
+
+// FromExtendedKey takes a base58 encoded extended private or public key and
+// returns an HDKey instance. The version bytes must match the given network
+func FromExtendedKey(extendedKey string, net *network.Network) (*HDKey, error) {
+	currentNetwork := networkOrDefault(net)
+	key, err := hdkeychain.NewKeyFromString(extendedKey)
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsForNet(chainParams(currentNetwork)) {
+		return nil, errors.New("extended key version does not match network")
+	}
+	return &HDKey{key, currentNetwork}, nil
+}
+
+// Derive returns the child key at the given BIP32 path, like m/84'/0'/0'/0/0.
+// Paths starting with m can be derived from master keys only, the others are
+// relative to this key. Hardened indexes are marked with ' or h and can not be
+// derived from public keys
+func (k *HDKey) Derive(path string) (*HDKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(path, "m") && k.key.Depth() != 0 {
+		return nil, errors.New("absolute path can be derived from master key only")
+	}
+
+	key := k.key
+	for _, index := range indexes {
+		if key, err = deriveChild(key, index); err != nil {
+			return nil, err
+		}
+	}
+	return &HDKey{key, k.network}, nil
+}
+
+// Neuter returns the extended public key of this key
+func (k *HDKey) Neuter() (*HDKey, error) {
+	key, err := k.key.Neuter()
+	if err != nil {
+		return nil, err
+	}
+	return &HDKey{key, k.network}, nil
+}
+
+// IsPrivate returns whether this is an extended private key
+func (k *HDKey) IsPrivate() bool {
+	return k.key.IsPrivate()
+}
+
+// KeyPair returns the pair of keys of this extended key. The private key is
+// nil for extended public keys
+func (k *HDKey) KeyPair() (*KeyPair, error) {
+	publicKey, err := k.key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	if !k.key.IsPrivate() {
+		return &KeyPair{PublicKey: publicKey}, nil
+	}
+	privateKey, err := k.key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{publicKey, privateKey}, nil
+}
+
+// String returns the base58 serialization of this extended key
+func (k *HDKey) String() string {
+	return k.key.String()
+}
+
+// ParsePath returns the child indexes of the given BIP32 path, with the
+// hardened ones offset by hdkeychain.HardenedKeyStart
+func ParsePath(path string) ([]uint32, error) {
+	elements := strings.Split(strings.TrimSpace(path), "/")
+	if elements[0] == "m" {
+		elements = elements[1:]
+	}
+
+	indexes := make([]uint32, 0, len(elements))
+	for _, element := range elements {
+		if element == "" {
+			return nil, fmt.Errorf("invalid path %s", path)
+		}
+		hardened := strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h")
+		if hardened {
+			element = element[:len(element)-1]
+		}
+		index, err := strconv.ParseUint(element, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("invalid path index %s", element)
+		}
+		if hardened {
+			index += hdkeychain.HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// deriveChild returns the child of the given key at the given index.
+// hdkeychain v1.0.2 drops the leading zero bytes of derived private keys and
+// does not pad them back when deriving hardened children, which yields wrong
+// keys (see BIP32 test vector 4). The key is parsed again from its
+// serialization, that is always padded to 32 bytes, before deriving.
+func deriveChild(key *hdkeychain.ExtendedKey, index uint32) (*hdkeychain.ExtendedKey, error) {
+	if key.IsPrivate() && index >= hdkeychain.HardenedKeyStart {
+		padded, err := hdkeychain.NewKeyFromString(key.String())
+		if err != nil {
+			return nil, err
+		}
+		key = padded
+	}
+	return key.Child(index)
+}
+
+// chainParams returns the params hdkeychain serializes extended keys with
+func chainParams(net *network.Network) *chaincfg.Params {
+	return &chaincfg.Params{
+		HDPrivateKeyID: net.HDPrivateKey,
+		HDPublicKeyID:  net.HDPublicKey,
+	}
+}
+
+func networkOrDefault(net *network.Network) *network.Network {
+	if net == nil {
+		return &network.Liquid
+	}
+	return net
+}
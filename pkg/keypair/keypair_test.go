@@ -0,0 +1,312 @@
+package keypair
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/vulpemventures/go-elements/network"
+)
+
+// BIP32 test vector 1, Liquid shares the version bytes of Bitcoin mainnet
+const seedHex = "000102030405060708090a0b0c0d0e0f"
+const masterXprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+const childXprv = "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs"
+const childXpub = "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"
+
+func TestHDKey(t *testing.T) {
+	seed, _ := hex.DecodeString(seedHex)
+	master, err := NewMasterKey(seed, &network.Liquid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if master.String() != masterXprv {
+		t.Fatalf("Got master key %s, expected %s", master.String(), masterXprv)
+	}
+
+	child, err := master.Derive("m/0'/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.String() != childXprv {
+		t.Fatalf("Got child key %s, expected %s", child.String(), childXprv)
+	}
+	childPublic, err := child.Neuter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if childPublic.String() != childXpub {
+		t.Fatalf("Got child public key %s, expected %s", childPublic.String(), childXpub)
+	}
+
+	// watch-only derivation from the extended public key
+	watchOnly, err := FromExtendedKey(childXpub, &network.Liquid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicGrandchild, err := watchOnly.Derive("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild, err := child.Derive("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPair, err := publicGrandchild.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := grandchild.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publicKeyPair.PrivateKey != nil || keyPair.PrivateKey == nil {
+		t.Fatal("Only keys derived from extended private keys must have a private key")
+	}
+	if !publicKeyPair.PublicKey.IsEqual(keyPair.PublicKey) {
+		t.Fatal("Public and private derivation do not match")
+	}
+
+	if _, err := watchOnly.Derive("2'"); err == nil {
+		t.Fatal("Should have failed deriving hardened child from public key")
+	}
+	if _, err := child.Derive("m/0"); err == nil {
+		t.Fatal("Should have failed deriving absolute path from child key")
+	}
+	if _, err := FromExtendedKey(childXpub, &network.Regtest); err == nil {
+		t.Fatal("Should have failed with extended key of another network")
+	}
+}
+
+func TestHDKeyVectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		seed     string
+		path     string
+		wantXprv string
+	}{
+		{
+			"vector 2",
+			"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+			"m/0/2147483647'/1/2147483646'/2",
+			"xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j",
+		},
+		{
+			"vector 3",
+			"4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be",
+			"m/0'",
+			"xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L",
+		},
+		{
+			// m/0' private key has a leading zero byte
+			"vector 4",
+			"3ddd5602285899a946114506157c7997e5444528f3003f6134712147db19b678",
+			"m/0'/1'",
+			"xprv9xJocDuwtYCMNAo3Zw76WENQeAS6WGXQ55RCy7tDJ8oALr4FWkuVoHJeHVAcAqiZLE7Je3vZJHxspZdFHfnBEjHqU5hG1Jaj32dVoS6XLT1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seed, _ := hex.DecodeString(tt.seed)
+			master, err := NewMasterKey(seed, &network.Liquid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			child, err := master.Derive(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if child.String() != tt.wantXprv {
+				t.Fatalf("Got key %s, expected %s", child.String(), tt.wantXprv)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []uint32
+		wantErr bool
+	}{
+		{"m/84'/1776'/0'/0/1", []uint32{0x80000054, 0x800006f0, 0x80000000, 0, 1}, false},
+		{"0h/1", []uint32{0x80000000, 1}, false},
+		{"m", []uint32{}, false},
+		{"m//1", nil, true},
+		{"m/x", nil, true},
+		{"m/2147483648", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePath(tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%s: should have failed parsing path", tt.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %v, expected %v", tt.path, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: got %v, expected %v", tt.path, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMnemonic(t *testing.T) {
+	// BIP39 test vectors with passphrase TREZOR
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			strings.Repeat("ff", 32),
+			strings.Repeat("zoo ", 23) + "vote",
+			"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+		},
+	}
+
+	for _, tt := range tests {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		mnemonic, err := NewMnemonic(len(entropy)*8, bytes.NewReader(entropy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != tt.mnemonic {
+			t.Fatalf("Got mnemonic %s, expected %s", mnemonic, tt.mnemonic)
+		}
+		gotEntropy, err := MnemonicToEntropy(mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotEntropy, entropy) {
+			t.Fatalf("Got entropy %x, expected %s", gotEntropy, tt.entropy)
+		}
+		seed, err := MnemonicToSeed(mnemonic, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(seed) != tt.seed {
+			t.Fatalf("Got seed %x, expected %s", seed, tt.seed)
+		}
+	}
+
+	if _, err := NewMnemonic(100, nil); err == nil {
+		t.Fatal("Should have failed with invalid entropy size")
+	}
+	if _, err := NewMnemonic(Mnemonic24Words, bytes.NewReader(make([]byte, 16))); err == nil {
+		t.Fatal("Should have failed with short entropy source")
+	}
+	mnemonic, err := NewMnemonic(Mnemonic12Words, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromMnemonic(mnemonic, "", &network.Liquid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalidMnemonics := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon wrongword",
+	}
+	for _, mnemonic := range invalidMnemonics {
+		if err := ValidateMnemonic(mnemonic); err == nil {
+			t.Fatalf("Should have failed validating mnemonic %s", mnemonic)
+		}
+	}
+}
+
+func TestMnemonicDerivation(t *testing.T) {
+	// BIP84 test vector, published keys are zprv encoded thus they are
+	// compared without the version bytes and the checksum
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	wantRoot := "zprvAWgYBBk7JR8Gjrh4UJQ2uJdG1r3WNRRfURiABBE3RvMXYSrRJL62XuezvGdPvG6GFBZduosCc1YP5wixPox7zhZLfiUm8aunE96BBa4Kei5"
+	wantAccount := "zprvAdG4iTXWBoARxkkzNpNh8r6Qag3irQB8PzEMkAFeTRXxHpbF9z4QgEvBRmfvqWvGp42t42nvgGpNgYSJA9iefm1yYNZKEm7z6qUWCroSQnE"
+
+	withoutVersion := func(extendedKey string) []byte {
+		decoded := base58.Decode(extendedKey)
+		return decoded[4 : len(decoded)-4]
+	}
+
+	master, err := FromMnemonic(mnemonic, "", &network.Liquid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutVersion(master.String()), withoutVersion(wantRoot)) {
+		t.Fatalf("Got master key %s, expected %s", master.String(), wantRoot)
+	}
+	account, err := master.Derive("m/84'/0'/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(withoutVersion(account.String()), withoutVersion(wantAccount)) {
+		t.Fatalf("Got account key %s, expected %s", account.String(), wantAccount)
+	}
+
+	// BIP39 test vector with passphrase TREZOR
+	wantXprv := "xprv9s21ZrQH143K3h3fDYiay8mocZ3afhfULfb5GX8kCBdno77K4HiA15Tg23wpbeF1pLfs1c5SPmYHrEpTuuRhxMwvKDwqdKiGJS9XFKzUsAF"
+	if master, err = FromMnemonic(mnemonic, "TREZOR", &network.Liquid); err != nil {
+		t.Fatal(err)
+	}
+	if master.String() != wantXprv {
+		t.Fatalf("Got master key %s, expected %s", master.String(), wantXprv)
+	}
+}
+
+func TestMasterBlindingKey(t *testing.T) {
+	// SLIP-77 test vectors of go-elements
+	seed, _ := hex.DecodeString("c76c4ac4f4e4a00d6b274d5c39c700bb4a7ddc04fbc6f78e85ca75007b5b495f74a9043eeb77bdd53aa6fc3a0e31462270316fa04b8c19114c8798706cd02ac8")
+	script, _ := hex.DecodeString("76a914a579388225827d9f2fe9014add644487808c695d88ac")
+
+	master, err := NewMasterBlindingKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(master.Bytes()); got != "6c2de18eabeff3f7822bc724ad482bef0557f3e1c1e1c75b7a393a5ced4de616" {
+		t.Fatalf("Got master blinding key %s", got)
+	}
+
+	restored, err := FromMasterBlindingKey(master.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := restored.PrivateKey(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(privateKey); got != "4e6e94df28448c7bb159271fe546da464ea863b3887d2eec6afd841184b70592" {
+		t.Fatalf("Got private blinding key %s", got)
+	}
+	publicKey, err := restored.PublicKey(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(publicKey); got != "0223ef5cf5d1185f86204b9386c8541061a24b6f72fa4a29e3a0b60e1c20ffaf5b" {
+		t.Fatalf("Got public blinding key %s", got)
+	}
+
+	if _, err := master.KeyPair(nil); err == nil {
+		t.Fatal("Should have failed deriving key pair of empty script")
+	}
+}
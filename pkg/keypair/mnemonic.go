@@ -0,0 +1,162 @@
+package keypair
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/vulpemventures/go-elements/network"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Mnemonic12Words is the entropy size in bits of a 12 words mnemonic
+	Mnemonic12Words = 128
+	// Mnemonic24Words is the entropy size in bits of a 24 words mnemonic
+	Mnemonic24Words = 256
+
+	bitsPerWord     = 11
+	seedIterations  = 2048
+	seedSize        = 64
+	seedSaltPrefix  = "mnemonic"
+	minEntropyBits  = 128
+	maxEntropyBits  = 256
+	entropyBitsStep = 32
+)
+
+var wordIndexes = func() map[string]int {
+	indexes := make(map[string]int, len(englishWordlist))
+	for i, word := range englishWordlist {
+		indexes[word] = i
+	}
+	return indexes
+}()
+
+// NewMnemonic returns a BIP39 english mnemonic for the given entropy size in
+// bits, a multiple of 32 between 128 and 256, like Mnemonic12Words and
+// Mnemonic24Words. Entropy is read from the given source or, if nil, from
+// crypto/rand
+func NewMnemonic(entropyBits int, entropySource io.Reader) (string, error) {
+	if err := validateEntropyBits(entropyBits); err != nil {
+		return "", err
+	}
+	if entropySource == nil {
+		entropySource = rand.Reader
+	}
+	entropy := make([]byte, entropyBits/8)
+	if _, err := io.ReadFull(entropySource, entropy); err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic returns the BIP39 english mnemonic encoding the given
+// entropy along with its checksum
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if err := validateEntropyBits(entropyBits); err != nil {
+		return "", err
+	}
+
+	checksumBits := entropyBits / 32
+	checksum := sha256.Sum256(entropy)
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(checksum[0]>>(8-checksumBits))))
+
+	numWords := (entropyBits + checksumBits) / bitsPerWord
+	words := make([]string, numWords)
+	mask := big.NewInt(1<<bitsPerWord - 1)
+	for i := numWords - 1; i >= 0; i-- {
+		index := new(big.Int).And(data, mask)
+		words[i] = englishWordlist[index.Int64()]
+		data.Rsh(data, bitsPerWord)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy returns the entropy encoded by the given mnemonic after
+// checking its words and checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	totalBits := len(words) * bitsPerWord
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+	if totalBits%33 != 0 || validateEntropyBits(entropyBits) != nil {
+		return nil, fmt.Errorf("invalid number of mnemonic words %d", len(words))
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndexes[word]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %s", word)
+		}
+		data.Lsh(data, bitsPerWord)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksum := new(big.Int).And(data, big.NewInt(1<<uint(checksumBits)-1))
+	data.Rsh(data, uint(checksumBits))
+	// leading zero bytes are dropped by Bytes, thus entropy is left padded
+	entropy := make([]byte, entropyBits/8)
+	dataBytes := data.Bytes()
+	copy(entropy[len(entropy)-len(dataBytes):], dataBytes)
+
+	wantChecksum := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(wantChecksum[0]>>(8-checksumBits)) {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic checks that the given mnemonic has a valid number of words,
+// all from the english wordlist, and a valid checksum
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed returns the BIP39 seed of the given mnemonic, protected by
+// the optional passphrase. Mnemonic and passphrase are expected to be NFKD
+// normalized, that is always the case for english mnemonics and ASCII
+// passphrases
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key(
+		[]byte(normalized),
+		[]byte(seedSaltPrefix+passphrase),
+		seedIterations,
+		seedSize,
+		sha512.New,
+	), nil
+}
+
+// FromMnemonic returns the master HDKey of the given mnemonic and optional
+// passphrase for the given network
+func FromMnemonic(mnemonic, passphrase string, net *network.Network) (*HDKey, error) {
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewMasterKey(seed, net)
+}
+
+func validateEntropyBits(entropyBits int) error {
+	if entropyBits < minEntropyBits || entropyBits > maxEntropyBits ||
+		entropyBits%entropyBitsStep != 0 {
+		return fmt.Errorf(
+			"entropy must be a multiple of 32 bits between 128 and 256, got %d",
+			entropyBits,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package multisig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/tiero/ocean/pkg/keypair"
+	"github.com/tiero/ocean/pkg/partial"
+	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/payment"
+)
+
+const (
+	// P2Wsh locks the multisig script into a native segwit output
+	P2Wsh = iota
+	// P2ShP2Wsh locks the multisig script into a segwit output wrapped in p2sh
+	P2ShP2Wsh
+)
+
+// Multisig defines an m-of-n multisig script shared among n cosigners
+type Multisig struct {
+	Required   int
+	PublicKeys []*btcec.PublicKey
+	Network    *network.Network
+	// Script is the multisig witness script
+	Script []byte
+}
+
+// FromPublicKeys returns a Multisig instance requiring the given number of
+// signatures out of the provided public keys. Keys are sorted
+// lexicographically (BIP67) so that every cosigner derives the same script
+// regardless of the order they are listed in.
+func FromPublicKeys(required int, publicKeys []*btcec.PublicKey, net *network.Network) (*Multisig, error) {
+	if required <= 0 {
+		return nil, errors.New("required signatures must be greater than 0")
+	}
+	if len(publicKeys) < required {
+		return nil, fmt.Errorf(
+			"required signatures %d exceed number of public keys %d",
+			required, len(publicKeys),
+		)
+	}
+
+	currentNetwork := &network.Liquid
+	if net != nil {
+		currentNetwork = net
+	}
+
+	sortedKeys := make([]*btcec.PublicKey, len(publicKeys))
+	copy(sortedKeys, publicKeys)
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		return bytes.Compare(
+			sortedKeys[i].SerializeCompressed(),
+			sortedKeys[j].SerializeCompressed(),
+		) < 0
+	})
+	for i := 1; i < len(sortedKeys); i++ {
+		if sortedKeys[i].IsEqual(sortedKeys[i-1]) {
+			return nil, errors.New("duplicated public key")
+		}
+	}
+
+	pay, err := payment.FromPublicKeys(sortedKeys, required, currentNetwork, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Multisig{
+		Required:   required,
+		PublicKeys: sortedKeys,
+		Network:    currentNetwork,
+		Script:     pay.Redeem.Script,
+	}, nil
+}
+
+// FromKeyPairs returns a Multisig instance requiring the given number of
+// signatures out of the public keys of the provided key pairs
+func FromKeyPairs(required int, keyPairs []*keypair.KeyPair, net *network.Network) (*Multisig, error) {
+	publicKeys := make([]*btcec.PublicKey, 0, len(keyPairs))
+	for _, kp := range keyPairs {
+		publicKeys = append(publicKeys, kp.PublicKey)
+	}
+	return FromPublicKeys(required, publicKeys, net)
+}
+
+// OutputScript returns the scriptPubKey locking funds to the multisig for
+// the given type
+func (m *Multisig) OutputScript(scriptType int) ([]byte, error) {
+	pay, err := m.payment(scriptType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if scriptType == P2Wsh {
+		return pay.WitnessScript, nil
+	}
+	return pay.Script, nil
+}
+
+// Address returns the unconfidential address of the multisig for the given
+// type
+func (m *Multisig) Address(scriptType int) (string, error) {
+	pay, err := m.payment(scriptType, nil)
+	if err != nil {
+		return "", err
+	}
+	if scriptType == P2Wsh {
+		return pay.WitnessScriptHash()
+	}
+	return pay.ScriptHash()
+}
+
+// ConfidentialAddress returns the confidential address of the multisig for
+// the given type, embedding the provided blinding public key
+func (m *Multisig) ConfidentialAddress(scriptType int, blindingKey *btcec.PublicKey) (string, error) {
+	if blindingKey == nil {
+		return "", errors.New("blinding key is missing")
+	}
+	pay, err := m.payment(scriptType, blindingKey)
+	if err != nil {
+		return "", err
+	}
+
+	var addr string
+	if scriptType == P2Wsh {
+		addr, err = pay.ConfidentialWitnessScriptHash()
+	} else {
+		addr, err = pay.ConfidentialScriptHash()
+	}
+	if err != nil {
+		return "", err
+	}
+	if addr == "" {
+		return "", errors.New("failed to encode confidential address")
+	}
+	return addr, nil
+}
+
+// Sign adds the signature of the given cosigner to the multisig input at the
+// given index of the partial transaction. The witness script is attached to
+// the input if not already present.
+func (m *Multisig) Sign(p *partial.Partial, index int, keyPair *keypair.KeyPair) error {
+	if !m.hasPublicKey(keyPair.PublicKey) {
+		return errors.New("key pair is not a cosigner of the multisig")
+	}
+
+	if index > (len(p.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	witnessScript := p.Data.Inputs[index].WitnessScript
+	if witnessScript == nil {
+		if err := p.AddInWitnessScript(index, m.Script); err != nil {
+			return err
+		}
+	} else if !bytes.Equal(witnessScript, m.Script) {
+		return errors.New("input witness script does not match multisig script")
+	}
+
+	return p.SignWithPrivateKey(index, keyPair)
+}
+
+// Finalize finalizes the multisig input at the given index of the partial
+// transaction once at least the required number of cosigners have signed it.
+// Signatures are pushed to the witness stack in the order the related keys
+// appear in the script.
+func (m *Multisig) Finalize(p *partial.Partial, index int) error {
+	if index > (len(p.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	input := p.Data.Inputs[index]
+	if !bytes.Equal(input.WitnessScript, m.Script) {
+		return errors.New("input witness script does not match multisig script")
+	}
+
+	// the empty element is consumed by the OP_CHECKMULTISIG off-by-one bug
+	stack := [][]byte{{}}
+	for _, key := range m.PublicKeys {
+		if len(stack) > m.Required {
+			break
+		}
+		serializedKey := key.SerializeCompressed()
+		for _, partialSig := range input.PartialSigs {
+			if bytes.Equal(partialSig.PubKey, serializedKey) {
+				stack = append(stack, partialSig.Signature)
+				break
+			}
+		}
+	}
+
+	if len(stack)-1 < m.Required {
+		return fmt.Errorf(
+			"input has %d signatures, %d required", len(stack)-1, m.Required,
+		)
+	}
+
+	return p.FinalizeWithStack(index, stack)
+}
+
+func (m *Multisig) hasPublicKey(publicKey *btcec.PublicKey) bool {
+	for _, key := range m.PublicKeys {
+		if key.IsEqual(publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// payment returns the go-elements payment for the multisig of the given type
+func (m *Multisig) payment(scriptType int, blindingKey *btcec.PublicKey) (*payment.Payment, error) {
+	redeem, err := payment.FromScript(m.Script, m.Network, blindingKey)
+	if err != nil {
+		return nil, err
+	}
+	p2wsh, err := payment.FromPayment(redeem)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scriptType {
+	case P2Wsh:
+		return p2wsh, nil
+	case P2ShP2Wsh:
+		return payment.FromPayment(&payment.Payment{
+			Script:      p2wsh.WitnessScript,
+			Network:     m.Network,
+			BlindingKey: blindingKey,
+		})
+	default:
+		return nil, errors.New("unsupported multisig script type")
+	}
+}
@@ -0,0 +1,223 @@
+package multisig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/tiero/ocean/pkg/keypair"
+	"github.com/tiero/ocean/pkg/partial"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/payment"
+)
+
+var cosignersHex = []string{
+	"bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86",
+	"1804e76aa3016013bc9969103554668913cf697c03c23aecb28136d0e0ac16f0",
+	"fd9123214784758c69351f45aebf3c719533a05c5fa017a466b4f31328487552",
+}
+
+func cosigners(t *testing.T) []*keypair.KeyPair {
+	keyPairs := make([]*keypair.KeyPair, 0, len(cosignersHex))
+	for _, privHex := range cosignersHex {
+		kp, err := keypair.FromPrivateKey(privHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPairs = append(keyPairs, kp)
+	}
+	return keyPairs
+}
+
+func TestFromKeyPairs(t *testing.T) {
+	keyPairs := cosigners(t)
+
+	m, err := FromKeyPairs(2, keyPairs, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := []*keypair.KeyPair{keyPairs[2], keyPairs[1], keyPairs[0]}
+	mReversed, err := FromKeyPairs(2, reversed, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(m.Script) != hex.EncodeToString(mReversed.Script) {
+		t.Fatal("Got different scripts for the same set of keys")
+	}
+
+	for _, scriptType := range []int{P2Wsh, P2ShP2Wsh} {
+		addr, err := m.Address(scriptType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		confAddr, err := m.ConfidentialAddress(scriptType, keyPairs[0].PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr == "" || confAddr == "" {
+			t.Fatal("Got empty address, expected not empty")
+		}
+	}
+
+	if _, err := FromKeyPairs(4, keyPairs, &network.Regtest); err == nil {
+		t.Fatal("Should have failed with too many required signatures")
+	}
+}
+
+func TestSignAndFinalize(t *testing.T) {
+	keyPairs := cosigners(t)
+	m, err := FromKeyPairs(2, keyPairs, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := payment.FromPublicKey(keyPairs[0].PublicKey, &network.Regtest, nil)
+
+	for _, scriptType := range []int{P2Wsh, P2ShP2Wsh} {
+		script, err := m.OutputScript(scriptType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := partial.NewPartial(&network.Regtest)
+		err = p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, &partial.WitnessUtxo{
+			Asset:  network.Regtest.AssetID,
+			Value:  100000000,
+			Script: script,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 99999500, recipient.Script, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.Sign(p, 0, keyPairs[2]); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Finalize(p, 0); err == nil {
+			t.Fatal("Should have failed with not enough signatures")
+		}
+		if err := m.Sign(p, 0, keyPairs[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Finalize(p, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		input := p.Data.Inputs[0]
+		if len(input.FinalScriptWitness) == 0 {
+			t.Fatal("Got empty final script witness, expected not empty")
+		}
+		if gotScriptSig := len(input.FinalScriptSig) > 0; gotScriptSig != (scriptType == P2ShP2Wsh) {
+			t.Fatalf("Got final script sig %v for script type %d", gotScriptSig, scriptType)
+		}
+	}
+}
+
+func TestSignCombineAndFinalize(t *testing.T) {
+	keyPairs := cosigners(t)
+	m, err := FromKeyPairs(2, keyPairs, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := payment.FromPublicKey(keyPairs[0].PublicKey, &network.Regtest, nil)
+	value, _ := confidential.SatoshiToElementsValue(100000000)
+
+	for _, scriptType := range []int{P2Wsh, P2ShP2Wsh} {
+		script, err := m.OutputScript(scriptType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := partial.NewPartial(&network.Regtest)
+		err = p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, &partial.WitnessUtxo{
+			Asset:  network.Regtest.AssetID,
+			Value:  100000000,
+			Script: script,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 99999500, recipient.Script, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddFeeOutput(500); err != nil {
+			t.Fatal(err)
+		}
+		unsigned, err := p.ToBase64()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// each cosigner signs its own copy, in reverse order of the keys in
+		// the script
+		signers := []*keypair.KeyPair{keyPairs[2], keyPairs[0]}
+		signed := make([]*partial.Partial, 0, len(signers))
+		for _, signer := range signers {
+			cosignerPartial, err := partial.FromBase64(unsigned, &network.Regtest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Sign(cosignerPartial, 0, signer); err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := cosignerPartial.ToBase64()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cosignerPartial, err = partial.FromBase64(encoded, &network.Regtest); err != nil {
+				t.Fatal(err)
+			}
+			signed = append(signed, cosignerPartial)
+		}
+
+		combined, err := partial.FromBase64(unsigned, &network.Regtest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := combined.Combine(signed...); err != nil {
+			t.Fatal(err)
+		}
+		if len(combined.Data.Inputs[0].PartialSigs) != len(signers) {
+			t.Fatalf("Got %d signatures, expected %d", len(combined.Data.Inputs[0].PartialSigs), len(signers))
+		}
+		if err := m.Finalize(combined, 0); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := combined.Extract()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// signatures must follow the order of the keys in the script
+		wantKeys := make([]*btcec.PublicKey, 0, len(signers))
+		for _, key := range m.PublicKeys {
+			for _, signer := range signers {
+				if key.IsEqual(signer.PublicKey) {
+					wantKeys = append(wantKeys, key)
+				}
+			}
+		}
+		witness := tx.Inputs[0].Witness
+		if len(witness) != len(signers)+2 {
+			t.Fatalf("Got witness of %d items, expected %d", len(witness), len(signers)+2)
+		}
+		if len(witness[0]) != 0 || !bytes.Equal(witness[len(witness)-1], m.Script) {
+			t.Fatal("Witness must start with an empty item and end with the multisig script")
+		}
+		hash := tx.HashForWitnessV0(0, m.Script, value[:], txscript.SigHashAll)
+		for i, key := range wantKeys {
+			sigBytes := witness[i+1]
+			sig, err := btcec.ParseDERSignature(sigBytes[:len(sigBytes)-1], btcec.S256())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sig.Verify(hash[:], key) {
+				t.Fatalf("Signature %d does not match key %x", i, key.SerializeCompressed())
+			}
+		}
+	}
+}
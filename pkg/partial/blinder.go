@@ -0,0 +1,675 @@
+package partial
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/pset"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+// blinder unblinds the inputs of a partial transaction and blinds its outputs
+// and issuance amounts. Pseudo inputs of issuances and reissuances are both
+// derived from the asset entropy committed in the input.
+//
+// It replaces the go-elements pset blinder, that derives the pseudo inputs of
+// reissuances wrongly and requires a blinding key for every output.
+type blinder struct {
+	data                 *pset.Pset
+	blindingPrivateKeys  [][]byte
+	unblindedInputs      []*UnblindedInput
+	blindingPublicKeys   [][]byte
+	outputBlindingKeys   map[int][]byte
+	issuanceBlindingKeys []pset.IssuanceBlindingPrivateKeys
+	// blindedIssuances tells, by input index, whether the issuances added to
+	// the Partial are to be blinded
+	blindedIssuances map[int]bool
+	policyAsset      string
+	// blindingData is filled with the data of the blinded outputs
+	blindingData map[int]*OutputBlindingData
+}
+
+// pseudoInput is the unblinded asset or token amount issued by an input
+type pseudoInput struct {
+	confidential.UnblindOutputResult
+	inputIndex int
+	isToken    bool
+}
+
+func (b *blinder) blind() error {
+	if err := b.validate(); err != nil {
+		return err
+	}
+
+	unblindedInputs, err := b.unblindInputs()
+	if err != nil {
+		return err
+	}
+	pseudoInputs, err := b.pseudoInputs()
+	if err != nil {
+		return err
+	}
+
+	for _, pseudoInput := range pseudoInputs {
+		unblindedInputs = append(unblindedInputs, pseudoInput.UnblindOutputResult)
+	}
+	err = validateBalance(unblindedInputs, b.data.UnsignedTx.Outputs, b.policyAsset)
+	if err != nil {
+		return err
+	}
+	if err := b.blindOutputs(unblindedInputs); err != nil {
+		return err
+	}
+
+	return b.blindIssuances(pseudoInputs)
+}
+
+func (b *blinder) validate() error {
+	if err := b.data.SanityCheck(); err != nil {
+		return err
+	}
+
+	for _, input := range b.data.Inputs {
+		if input.NonWitnessUtxo == nil && input.WitnessUtxo == nil {
+			return errors.New(
+				"all inputs must contain a non witness utxo or a witness utxo",
+			)
+		}
+		if len(input.PartialSigs) > 0 {
+			return errors.New("inputs must not contain signatures")
+		}
+	}
+
+	if len(b.blindingPrivateKeys) == 0 && len(b.unblindedInputs) == 0 {
+		return errors.New("missing blinding private keys or unblinded inputs")
+	}
+	if len(b.blindingPrivateKeys) > 0 && len(b.blindingPrivateKeys) != len(b.data.Inputs) {
+		return errors.New("blinding private keys do not match the number of inputs")
+	}
+	if len(b.unblindedInputs) > 0 && len(b.unblindedInputs) != len(b.data.Inputs) {
+		return errors.New("unblinded inputs do not match the number of inputs")
+	}
+	if err := b.validateOutputKeys(); err != nil {
+		return err
+	}
+	if len(b.issuanceBlindingKeys) > 0 && len(b.issuanceBlindingKeys) != len(b.data.Inputs) {
+		return errors.New("issuance blinding keys do not match the number of inputs")
+	}
+	return b.validateIssuances()
+}
+
+// validateIssuances checks that issuance blinding keys are given for the
+// issuances added as blinded, and only for them. The reissuance token of a
+// new issuance depends on whether its amounts are confidential, thus, if the
+// issuance has not been added to this Partial, the token output added with it
+// tells how it has to be blinded
+func (b *blinder) validateIssuances() error {
+	for index, input := range b.data.UnsignedTx.Inputs {
+		if !input.HasIssuance() || isReissuance(input.Issuance) {
+			continue
+		}
+
+		if added, ok := b.blindedIssuances[index]; ok {
+			if err := validateIssuanceBlinding(index, added, b.isIssuanceBlinded(index)); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(input.Issuance.TokenAmount) <= 1 {
+			continue
+		}
+
+		_, confidentialToken, err := issuanceAssets(input, true)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", index, err)
+		}
+		_, explicitToken, err := issuanceAssets(input, false)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", index, err)
+		}
+
+		blinded := b.isIssuanceBlinded(index)
+		for _, output := range b.data.UnsignedTx.Outputs {
+			// only explicit assets, or marked as to be blinded, are comparable
+			if len(output.Asset) != 33 || output.Asset[0] > 0x01 {
+				continue
+			}
+			if bytes.Equal(output.Asset[1:], explicitToken) {
+				if err := validateIssuanceBlinding(index, false, blinded); err != nil {
+					return err
+				}
+			}
+			if bytes.Equal(output.Asset[1:], confidentialToken) {
+				if err := validateIssuanceBlinding(index, true, blinded); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateIssuanceBlinding checks that the issuance of the input at the given
+// index is blinded as it has been added
+func validateIssuanceBlinding(index int, added, blinded bool) error {
+	if blinded && !added {
+		return fmt.Errorf(
+			"input %d: issuance has been added as unblinded, "+
+				"issuance blinding keys must not be given", index,
+		)
+	}
+	if !blinded && added {
+		return fmt.Errorf(
+			"input %d: issuance has been added as blinded, "+
+				"missing issuance blinding keys", index,
+		)
+	}
+	return nil
+}
+
+// validateOutputKeys checks that every output to blind has a blinding public
+// key. With the parallel array of keys all outputs except fee and burn ones
+// are blinded, while with the keys mapped by output index only the given
+// outputs are, and they must include all the outputs marked as blinded
+func (b *blinder) validateOutputKeys() error {
+	outputs := b.data.UnsignedTx.Outputs
+	if b.outputBlindingKeys == nil {
+		if len(b.blindingPublicKeys) != len(outputs) {
+			return errors.New("blinding public keys do not match the number of outputs")
+		}
+		for index, output := range outputs {
+			if isBlindable(output) && len(b.blindingPublicKeys[index]) == 0 {
+				return fmt.Errorf("output %d: missing blinding public key", index)
+			}
+		}
+		return nil
+	}
+
+	if len(b.blindingPublicKeys) > 0 {
+		return errors.New(
+			"blinding public keys and output blinding keys can not be used together",
+		)
+	}
+	for index, key := range b.outputBlindingKeys {
+		if index < 0 || index > len(outputs)-1 {
+			return fmt.Errorf("output %d: index out of range", index)
+		}
+		if !isBlindable(outputs[index]) {
+			return fmt.Errorf("output %d: fee and burn outputs can not be blinded", index)
+		}
+		if outputs[index].IsConfidential() {
+			return fmt.Errorf("output %d: output is already blinded", index)
+		}
+		if len(key) == 0 {
+			return fmt.Errorf("output %d: missing blinding public key", index)
+		}
+	}
+	for index, output := range outputs {
+		if _, ok := b.outputBlindingKeys[index]; !ok && output.Asset[0] == 0x00 {
+			return fmt.Errorf(
+				"output %d: missing blinding public key for output marked as blinded",
+				index,
+			)
+		}
+	}
+	return nil
+}
+
+// shouldBlind returns whether the output at the given index must be blinded
+func (b *blinder) shouldBlind(index int) bool {
+	if b.outputBlindingKeys != nil {
+		_, ok := b.outputBlindingKeys[index]
+		return ok
+	}
+	return isBlindable(b.data.UnsignedTx.Outputs[index])
+}
+
+// blindingPublicKey returns the blinding public key of the output at the
+// given index
+func (b *blinder) blindingPublicKey(index int) []byte {
+	if b.outputBlindingKeys != nil {
+		return b.outputBlindingKeys[index]
+	}
+	return b.blindingPublicKeys[index]
+}
+
+// unblindInputs returns the asset, value and blinding factors of the prevouts
+// of all inputs. Explicit prevouts have zero blinding factors. Confidential
+// prevouts are taken from the unblinded inputs, if provided, or unblinded with
+// the private blinding keys otherwise
+func (b *blinder) unblindInputs() ([]confidential.UnblindOutputResult, error) {
+	unblinded := make([]confidential.UnblindOutputResult, 0, len(b.data.Inputs))
+	for index := range b.data.Inputs {
+		prevout, err := prevoutOf(b.data, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if !prevout.IsConfidential() {
+			value, err := explicitValue(prevout.Value)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %w", index, err)
+			}
+			unblinded = append(unblinded, confidential.UnblindOutputResult{
+				Value:               value,
+				Asset:               prevout.Asset[1:],
+				ValueBlindingFactor: make([]byte, 32),
+				AssetBlindingFactor: make([]byte, 32),
+			})
+			continue
+		}
+
+		if len(b.unblindedInputs) > 0 && b.unblindedInputs[index] != nil {
+			output, err := b.unblindedInputs[index].verify(prevout)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %w", index, err)
+			}
+			unblinded = append(unblinded, *output)
+			continue
+		}
+
+		if len(b.blindingPrivateKeys) == 0 || len(b.blindingPrivateKeys[index]) == 0 {
+			return nil, fmt.Errorf(
+				"input %d: missing blinding private key or unblinded input", index,
+			)
+		}
+		nonce, err := confidential.NonceHash(prevout.Nonce, b.blindingPrivateKeys[index])
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", index, err)
+		}
+		output, err := confidential.UnblindOutput(confidential.UnblindOutputArg{
+			Nonce:           nonce,
+			Rangeproof:      prevout.RangeProof,
+			ValueCommitment: prevout.Value,
+			AssetCommitment: prevout.Asset,
+			ScriptPubkey:    prevout.Script,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", index, err)
+		}
+		unblinded = append(unblinded, *output)
+	}
+	return unblinded, nil
+}
+
+// pseudoInputs returns the asset and token amounts issued by the inputs.
+// Amounts of confidential issuances get a random value blinding factor, while
+// asset blinding factors are always zero
+func (b *blinder) pseudoInputs() ([]pseudoInput, error) {
+	pseudoInputs := make([]pseudoInput, 0)
+	for index, input := range b.data.UnsignedTx.Inputs {
+		if !input.HasIssuance() {
+			continue
+		}
+
+		blinded := b.isIssuanceBlinded(index)
+		asset, token, err := issuanceAssets(input, blinded)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", index, err)
+		}
+
+		amounts := []struct {
+			asset   []byte
+			value   []byte
+			isToken bool
+		}{
+			{asset, input.Issuance.AssetAmount, false},
+			{token, input.Issuance.TokenAmount, true},
+		}
+		for _, amount := range amounts {
+			// a null amount is encoded with the single 0x00 byte
+			if len(amount.value) <= 1 {
+				continue
+			}
+			value, err := explicitValue(amount.value)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: issuance amount: %w", index, err)
+			}
+			vbf := make([]byte, 32)
+			if blinded {
+				if vbf, err = generateRandomNumber(); err != nil {
+					return nil, err
+				}
+			}
+			pseudoInputs = append(pseudoInputs, pseudoInput{
+				UnblindOutputResult: confidential.UnblindOutputResult{
+					Value:               value,
+					Asset:               amount.asset,
+					ValueBlindingFactor: vbf,
+					AssetBlindingFactor: make([]byte, 32),
+				},
+				inputIndex: index,
+				isToken:    amount.isToken,
+			})
+		}
+	}
+	return pseudoInputs, nil
+}
+
+// blindOutputs blinds the outputs with a blinding public key against the
+// given unblinded inputs. Fee outputs, with empty script, and OP_RETURN
+// outputs, like burns, are always left explicit
+func (b *blinder) blindOutputs(unblindedInputs []confidential.UnblindOutputResult) error {
+	inValues := make([]uint64, 0, len(unblindedInputs))
+	inAssets := make([][]byte, 0, len(unblindedInputs))
+	inAbfs := make([][]byte, 0, len(unblindedInputs))
+	inVbfs := make([][]byte, 0, len(unblindedInputs))
+	for _, input := range unblindedInputs {
+		inValues = append(inValues, input.Value)
+		inAssets = append(inAssets, input.Asset)
+		inAbfs = append(inAbfs, input.AssetBlindingFactor)
+		inVbfs = append(inVbfs, input.ValueBlindingFactor)
+	}
+
+	outIndexes := make([]int, 0)
+	outValues := make([]uint64, 0)
+	for index, output := range b.data.UnsignedTx.Outputs {
+		if !b.shouldBlind(index) {
+			continue
+		}
+		value, err := explicitValue(output.Value)
+		if err != nil {
+			return fmt.Errorf("output %d: %w", index, err)
+		}
+		outIndexes = append(outIndexes, index)
+		outValues = append(outValues, value)
+	}
+	if len(outIndexes) == 0 {
+		for _, input := range unblindedInputs {
+			if !bytes.Equal(input.ValueBlindingFactor, make([]byte, 32)) {
+				return errors.New(
+					"at least one output must be blinded to spend confidential inputs",
+				)
+			}
+		}
+		return nil
+	}
+
+	outAbfs := make([][]byte, 0, len(outIndexes))
+	outVbfs := make([][]byte, 0, len(outIndexes))
+	for i := range outIndexes {
+		abf, err := generateRandomNumber()
+		if err != nil {
+			return err
+		}
+		outAbfs = append(outAbfs, abf)
+		if i < len(outIndexes)-1 {
+			vbf, err := generateRandomNumber()
+			if err != nil {
+				return err
+			}
+			outVbfs = append(outVbfs, vbf)
+		}
+	}
+
+	finalVbf, err := confidential.FinalValueBlindingFactor(
+		confidential.FinalValueBlindingFactorArg{
+			InValues:      inValues,
+			OutValues:     outValues,
+			InGenerators:  inAbfs,
+			OutGenerators: outAbfs,
+			InFactors:     inVbfs,
+			OutFactors:    outVbfs,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	outVbfs = append(outVbfs, finalVbf[:])
+
+	for i, index := range outIndexes {
+		err := b.blindOutput(index, outValues[i], outAbfs[i], outVbfs[i], inAssets, inAbfs)
+		if err != nil {
+			return fmt.Errorf("output %d: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// blindOutput replaces asset and value of the output at the given index with
+// their commitments and adds the nonce, range proof and surjection proof
+func (b *blinder) blindOutput(index int, value uint64, abf, vbf []byte, inAssets, inAbfs [][]byte) error {
+	output := b.data.UnsignedTx.Outputs[index]
+	asset := output.Asset[1:]
+
+	assetCommitment, err := confidential.AssetCommitment(asset, abf)
+	if err != nil {
+		return err
+	}
+	valueCommitment, err := confidential.ValueCommitment(value, assetCommitment[:], vbf)
+	if err != nil {
+		return err
+	}
+
+	ephemeralPrivateKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return err
+	}
+	nonce, err := confidential.NonceHash(
+		b.blindingPublicKey(index),
+		ephemeralPrivateKey.Serialize(),
+	)
+	if err != nil {
+		return err
+	}
+
+	var vbf32 [32]byte
+	copy(vbf32[:], vbf)
+	rangeProof, err := confidential.RangeProof(confidential.RangeProofArg{
+		Value:               value,
+		Nonce:               nonce,
+		Asset:               asset,
+		AssetBlindingFactor: abf,
+		ValueBlindFactor:    vbf32,
+		ValueCommit:         valueCommitment[:],
+		ScriptPubkey:        output.Script,
+		MinValue:            1,
+		Exp:                 0,
+		MinBits:             52,
+	})
+	if err != nil {
+		return err
+	}
+
+	inCommitments := make([][]byte, 0, len(inAssets))
+	for i := range inAssets {
+		commitment, err := confidential.AssetCommitment(inAssets[i], inAbfs[i])
+		if err != nil {
+			return err
+		}
+		inCommitments = append(inCommitments, commitment[:])
+	}
+	// go-elements may create the proof against input tags other than the
+	// given ones, see parseGenerators, thus it is created until valid
+	var surjectionProof []byte
+	for attempt := 0; attempt < maxSurjectionProofAttempts; attempt++ {
+		seed, err := generateRandomNumber()
+		if err != nil {
+			return err
+		}
+		surjectionProof, err = confidential.SurjectionProof(confidential.SurjectionProofArg{
+			OutputAsset:               asset,
+			OutputAssetBlindingFactor: abf,
+			InputAssets:               inAssets,
+			InputAssetBlindingFactors: inAbfs,
+			Seed:                      seed,
+		})
+		if err == nil {
+			err = verifySurjectionProof(surjectionProof, assetCommitment[:], inCommitments)
+		}
+		if err == nil {
+			break
+		}
+		if attempt == maxSurjectionProofAttempts-1 {
+			return fmt.Errorf("unable to create a valid surjection proof: %w", err)
+		}
+	}
+
+	if b.blindingData == nil {
+		b.blindingData = make(map[int]*OutputBlindingData)
+	}
+	b.blindingData[index] = &OutputBlindingData{
+		Asset:        assetHashToHex(asset),
+		Value:        value,
+		AssetBlinder: abf,
+		ValueBlinder: vbf,
+		Nonce:        nonce[:],
+	}
+
+	output.Asset = assetCommitment[:]
+	output.Value = valueCommitment[:]
+	output.Nonce = ephemeralPrivateKey.PubKey().SerializeCompressed()
+	output.RangeProof = rangeProof
+	output.SurjectionProof = surjectionProof
+	return nil
+}
+
+// blindIssuances replaces the amounts of the confidential issuances with
+// their commitments and adds the related range proofs
+func (b *blinder) blindIssuances(pseudoInputs []pseudoInput) error {
+	for _, pseudoInput := range pseudoInputs {
+		if !b.isIssuanceBlinded(pseudoInput.inputIndex) {
+			continue
+		}
+
+		key := b.issuanceBlindingKeys[pseudoInput.inputIndex].AssetKey
+		if pseudoInput.isToken {
+			key = b.issuanceBlindingKeys[pseudoInput.inputIndex].TokenKey
+		}
+		if len(key) != 32 {
+			return fmt.Errorf(
+				"input %d: missing issuance blinding key", pseudoInput.inputIndex,
+			)
+		}
+
+		assetCommitment, err := confidential.AssetCommitment(
+			pseudoInput.Asset,
+			pseudoInput.AssetBlindingFactor,
+		)
+		if err != nil {
+			return err
+		}
+		valueCommitment, err := confidential.ValueCommitment(
+			pseudoInput.Value,
+			assetCommitment[:],
+			pseudoInput.ValueBlindingFactor,
+		)
+		if err != nil {
+			return err
+		}
+
+		var nonce, vbf32 [32]byte
+		copy(nonce[:], key)
+		copy(vbf32[:], pseudoInput.ValueBlindingFactor)
+		rangeProof, err := confidential.RangeProof(confidential.RangeProofArg{
+			Value:               pseudoInput.Value,
+			Nonce:               nonce,
+			Asset:               pseudoInput.Asset,
+			AssetBlindingFactor: pseudoInput.AssetBlindingFactor,
+			ValueBlindFactor:    vbf32,
+			ValueCommit:         valueCommitment[:],
+			ScriptPubkey:        []byte{},
+			MinValue:            1,
+			Exp:                 0,
+			MinBits:             52,
+		})
+		if err != nil {
+			return err
+		}
+
+		input := b.data.UnsignedTx.Inputs[pseudoInput.inputIndex]
+		if pseudoInput.isToken {
+			input.InflationRangeProof = rangeProof
+			input.Issuance.TokenAmount = valueCommitment[:]
+		} else {
+			input.IssuanceRangeProof = rangeProof
+			input.Issuance.AssetAmount = valueCommitment[:]
+		}
+	}
+	return nil
+}
+
+// isIssuanceBlinded returns whether the issuance amounts of the input at the
+// given index must be blinded, that is if a blinding key is provided for it
+func (b *blinder) isIssuanceBlinded(index int) bool {
+	if len(b.issuanceBlindingKeys) == 0 {
+		return false
+	}
+	keys := b.issuanceBlindingKeys[index]
+	return len(keys.AssetKey) > 0 || len(keys.TokenKey) > 0
+}
+
+// verify checks that asset, value and blinding factors of the unblinded input
+// match the commitments of the given confidential prevout and returns them
+func (u *UnblindedInput) verify(prevout *transaction.TxOutput) (*confidential.UnblindOutputResult, error) {
+	if len(u.AssetBlinder) != 32 || len(u.ValueBlinder) != 32 {
+		return nil, errors.New("blinding factors must be 32 bytes long")
+	}
+	asset, err := AssetHashToBytes(u.Asset, false)
+	if err != nil {
+		return nil, err
+	}
+	assetCommitment, err := confidential.AssetCommitment(asset[1:], u.AssetBlinder)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(assetCommitment[:], prevout.Asset) {
+		return nil, errors.New("asset and blinder do not match prevout asset commitment")
+	}
+	valueCommitment, err := confidential.ValueCommitment(u.Value, assetCommitment[:], u.ValueBlinder)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(valueCommitment[:], prevout.Value) {
+		return nil, errors.New("value and blinder do not match prevout value commitment")
+	}
+	return &confidential.UnblindOutputResult{
+		Value:               u.Value,
+		Asset:               asset[1:],
+		ValueBlindingFactor: u.ValueBlinder,
+		AssetBlindingFactor: u.AssetBlinder,
+	}, nil
+}
+
+// isBlindable returns whether the output can be blinded, that is neither a
+// fee output nor an unspendable OP_RETURN output
+func isBlindable(output *transaction.TxOutput) bool {
+	return !isFeeOutput(output) && output.Script[0] != txscript.OP_RETURN
+}
+
+// prevoutOf returns the output spent by the input of the pset at the given
+// index
+func prevoutOf(data *pset.Pset, index int) (*transaction.TxOutput, error) {
+	input := data.Inputs[index]
+	if input.WitnessUtxo != nil {
+		return input.WitnessUtxo, nil
+	}
+	if input.NonWitnessUtxo != nil {
+		outIndex := data.UnsignedTx.Inputs[index].Index
+		return input.NonWitnessUtxo.Outputs[outIndex], nil
+	}
+	return nil, errors.New("input is missing both witness and non witness utxo")
+}
+
+// explicitValue returns the amount in satoshi of an explicit elements value
+func explicitValue(value []byte) (uint64, error) {
+	if len(value) != confidential.ElementsUnconfidentialValueLength {
+		return 0, errors.New("value is not explicit")
+	}
+	var val [confidential.ElementsUnconfidentialValueLength]byte
+	copy(val[:], value)
+	return confidential.ElementsToSatoshiValue(val)
+}
+
+func generateRandomNumber() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
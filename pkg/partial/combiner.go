@@ -0,0 +1,207 @@
+package partial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/vulpemventures/go-elements/pset"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+// Combine merges signatures, scripts and any other input and output metadata
+// of the given partial transactions into the current one. All of them must
+// share the same unsigned transaction. In case of conflicting data an error
+// is returned and the current partial transaction is left untouched.
+func (p *Partial) Combine(others ...*Partial) error {
+	unsignedTx, err := p.Data.UnsignedTx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	// merge into a copy so that p is not modified if any conflict is found
+	psetBytes, err := p.ToBytes()
+	if err != nil {
+		return err
+	}
+	combined, err := FromBytes(psetBytes, p.Network)
+	if err != nil {
+		return err
+	}
+
+	for i, other := range others {
+		if other.Network.Name != p.Network.Name {
+			return fmt.Errorf("partial %d: network does not match", i)
+		}
+		otherUnsignedTx, err := other.Data.UnsignedTx.Serialize()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(unsignedTx, otherUnsignedTx) {
+			return fmt.Errorf("partial %d: unsigned transaction does not match", i)
+		}
+
+		for index := range combined.Data.Inputs {
+			err := combineInput(&combined.Data.Inputs[index], other.Data.Inputs[index])
+			if err != nil {
+				return fmt.Errorf("partial %d: input %d: %w", i, index, err)
+			}
+		}
+		for index := range combined.Data.Outputs {
+			err := combineOutput(&combined.Data.Outputs[index], other.Data.Outputs[index])
+			if err != nil {
+				return fmt.Errorf("partial %d: output %d: %w", i, index, err)
+			}
+		}
+	}
+
+	if err := combined.Data.SanityCheck(); err != nil {
+		return err
+	}
+
+	p.Data = combined.Data
+	return nil
+}
+
+func combineInput(dst *pset.PInput, src pset.PInput) error {
+	dstFinalized := dst.FinalScriptSig != nil || dst.FinalScriptWitness != nil
+	srcFinalized := src.FinalScriptSig != nil || src.FinalScriptWitness != nil
+	switch {
+	case dstFinalized && srcFinalized:
+		if !bytes.Equal(dst.FinalScriptSig, src.FinalScriptSig) ||
+			!bytes.Equal(dst.FinalScriptWitness, src.FinalScriptWitness) {
+			return errors.New("conflicting final scripts")
+		}
+		return nil
+	case dstFinalized:
+		return nil
+	case srcFinalized:
+		*dst = src
+		return nil
+	}
+
+	if src.NonWitnessUtxo != nil {
+		if dst.NonWitnessUtxo == nil {
+			dst.NonWitnessUtxo = src.NonWitnessUtxo
+		} else if dst.NonWitnessUtxo.TxHash() != src.NonWitnessUtxo.TxHash() {
+			return errors.New("conflicting non witness utxo")
+		}
+	}
+
+	if src.WitnessUtxo != nil {
+		if dst.WitnessUtxo == nil {
+			dst.WitnessUtxo = src.WitnessUtxo
+		} else if !isSameTxOutput(dst.WitnessUtxo, src.WitnessUtxo) {
+			return errors.New("conflicting witness utxo")
+		}
+	}
+
+	if src.SighashType != 0 {
+		if dst.SighashType == 0 {
+			dst.SighashType = src.SighashType
+		} else if dst.SighashType != src.SighashType {
+			return errors.New("conflicting sighash type")
+		}
+	}
+
+	redeemScript, err := combineScript(dst.RedeemScript, src.RedeemScript)
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	dst.RedeemScript = redeemScript
+
+	witnessScript, err := combineScript(dst.WitnessScript, src.WitnessScript)
+	if err != nil {
+		return fmt.Errorf("witness script: %w", err)
+	}
+	dst.WitnessScript = witnessScript
+
+	for _, srcSig := range src.PartialSigs {
+		found := false
+		for _, dstSig := range dst.PartialSigs {
+			if bytes.Equal(dstSig.PubKey, srcSig.PubKey) {
+				if !bytes.Equal(dstSig.Signature, srcSig.Signature) {
+					return errors.New("conflicting partial signatures")
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.PartialSigs = append(dst.PartialSigs, srcSig)
+		}
+	}
+
+	dst.Bip32Derivation = combineBip32Derivation(dst.Bip32Derivation, src.Bip32Derivation)
+
+	for _, srcUnknown := range src.Unknowns {
+		found := false
+		for _, dstUnknown := range dst.Unknowns {
+			if bytes.Equal(dstUnknown.Key, srcUnknown.Key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Unknowns = append(dst.Unknowns, srcUnknown)
+		}
+	}
+
+	return nil
+}
+
+func combineOutput(dst *pset.POutput, src pset.POutput) error {
+	redeemScript, err := combineScript(dst.RedeemScript, src.RedeemScript)
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	dst.RedeemScript = redeemScript
+
+	witnessScript, err := combineScript(dst.WitnessScript, src.WitnessScript)
+	if err != nil {
+		return fmt.Errorf("witness script: %w", err)
+	}
+	dst.WitnessScript = witnessScript
+
+	dst.Bip32Derivation = combineBip32Derivation(dst.Bip32Derivation, src.Bip32Derivation)
+	return nil
+}
+
+func combineScript(dst, src []byte) ([]byte, error) {
+	if src == nil {
+		return dst, nil
+	}
+	if dst == nil {
+		return src, nil
+	}
+	if !bytes.Equal(dst, src) {
+		return nil, errors.New("conflicting scripts")
+	}
+	return dst, nil
+}
+
+func combineBip32Derivation(dst, src []*psbt.Bip32Derivation) []*psbt.Bip32Derivation {
+	for _, srcDerivation := range src {
+		found := false
+		for _, dstDerivation := range dst {
+			if bytes.Equal(dstDerivation.PubKey, srcDerivation.PubKey) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, srcDerivation)
+		}
+	}
+	return dst
+}
+
+func isSameTxOutput(a, b *transaction.TxOutput) bool {
+	return bytes.Equal(a.Asset, b.Asset) &&
+		bytes.Equal(a.Value, b.Value) &&
+		bytes.Equal(a.Script, b.Script) &&
+		bytes.Equal(a.Nonce, b.Nonce) &&
+		bytes.Equal(a.RangeProof, b.RangeProof) &&
+		bytes.Equal(a.SurjectionProof, b.SurjectionProof)
+}
@@ -0,0 +1,65 @@
+package partial
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/pset"
+)
+
+// FromBase64 returns a Partial instance from a base64 encoded pset and the
+// selected Network
+func FromBase64(psetBase64 string, net *network.Network) (*Partial, error) {
+	data, err := pset.NewPsetFromBase64(psetBase64)
+	if err != nil {
+		return nil, err
+	}
+	return fromPset(data, net), nil
+}
+
+// FromHex returns a Partial instance from a hex encoded pset and the selected
+// Network
+func FromHex(psetHex string, net *network.Network) (*Partial, error) {
+	data, err := pset.NewPsetFromHex(psetHex)
+	if err != nil {
+		return nil, err
+	}
+	return fromPset(data, net), nil
+}
+
+// FromBytes returns a Partial instance from a binary serialized pset and the
+// selected Network
+func FromBytes(psetBytes []byte, net *network.Network) (*Partial, error) {
+	if len(psetBytes) == 0 {
+		return nil, errors.New("pset is empty")
+	}
+	return FromHex(hex.EncodeToString(psetBytes), net)
+}
+
+// ToBase64 returns the base64 encoding of the partial transaction
+func (p *Partial) ToBase64() (string, error) {
+	return p.Data.ToBase64()
+}
+
+// ToHex returns the hex encoding of the partial transaction
+func (p *Partial) ToHex() (string, error) {
+	return p.Data.ToHex()
+}
+
+// ToBytes returns the binary serialization of the partial transaction
+func (p *Partial) ToBytes() ([]byte, error) {
+	psetHex, err := p.Data.ToHex()
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(psetHex)
+}
+
+func fromPset(data *pset.Pset, net *network.Network) *Partial {
+	currentNetwork := &network.Liquid
+	if net != nil {
+		currentNetwork = net
+	}
+	return &Partial{Data: data, Network: currentNetwork}
+}
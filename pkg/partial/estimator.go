@@ -0,0 +1,323 @@
+package partial
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+const (
+	// sigSize is the maximum size of a DER signature plus the sighash byte
+	sigSize = 73
+	// pubKeySize is the size of a compressed public key
+	pubKeySize = 33
+	// commitmentSize is the size of asset and value commitments and nonces
+	commitmentSize = 33
+	// rangeProofSize is the size of the 52 bits range proofs of blinded
+	// outputs
+	rangeProofSize = 4174
+	// maxSurjectionInputs is the max number of inputs surjection proofs use
+	maxSurjectionInputs = 3
+)
+
+// EstimateArgs defines the outputs and the issuances that are going to be
+// blinded, as passed to Blind, so that their commitments and proofs are
+// accounted in the estimated size
+type EstimateArgs struct {
+	// BlindedOutputs are the indexes of the outputs to blind
+	BlindedOutputs []int
+	// BlindedIssuances are the indexes of the inputs whose issuance amounts
+	// are to blind
+	BlindedIssuances []int
+}
+
+// EstimateVirtualSize returns the estimated virtual size of the final signed
+// transaction. Scripts and witnesses of the inputs not yet finalized are
+// estimated from the type of their prevout. The outputs and issuances to blind
+// given with args are accounted with their commitments, range and surjection
+// proofs. Fee and burn outputs can not be blinded.
+func (p *Partial) EstimateVirtualSize(args EstimateArgs) (int, error) {
+	return p.estimateVirtualSize(p.Data.UnsignedTx.Copy(), args)
+}
+
+// SetFeeWithRate sets the fee output, added if missing, to pay the given fee
+// rate in satoshi per virtual byte for the estimated size of the transaction,
+// with the outputs and issuances to blind given with args. The change output
+// at the given index, explicit and in the policy asset, is adjusted so that
+// inputs and outputs keep balancing. The fee is returned.
+func (p *Partial) SetFeeWithRate(satPerVByte float64, changeIndex int, args EstimateArgs) (uint64, error) {
+	if satPerVByte <= 0 {
+		return 0, errors.New("fee rate must be greater than 0")
+	}
+	for _, input := range p.Data.Inputs {
+		if len(input.PartialSigs) > 0 {
+			return 0, errors.New("fee can not be set for a signed transaction")
+		}
+	}
+
+	outputs := p.Data.UnsignedTx.Outputs
+	if changeIndex < 0 || changeIndex > len(outputs)-1 {
+		return 0, errors.New("change index out of range")
+	}
+	change := outputs[changeIndex]
+	if isFeeOutput(change) {
+		return 0, errors.New("change output can not be the fee output")
+	}
+	if change.Asset[0] > 0x01 || len(change.Value) != confidential.ElementsUnconfidentialValueLength {
+		return 0, errors.New("change output is already blinded")
+	}
+	if assetHashToHex(change.Asset[1:]) != p.Network.AssetID {
+		return 0, errors.New("change output asset does not match network policy asset")
+	}
+	changeValue, err := explicitValue(change.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	feeIndex := -1
+	var currentFee uint64
+	for index, output := range outputs {
+		if !isFeeOutput(output) {
+			continue
+		}
+		if feeIndex >= 0 {
+			return 0, errors.New("transaction contains more than one fee output")
+		}
+		if currentFee, err = explicitValue(output.Value); err != nil {
+			return 0, err
+		}
+		feeIndex = index
+	}
+
+	tx := p.Data.UnsignedTx.Copy()
+	if feeIndex < 0 {
+		elementsAsset, err := AssetHashToBytes(p.Network.AssetID, false)
+		if err != nil {
+			return 0, err
+		}
+		zeroValue, _ := confidential.SatoshiToElementsValue(0)
+		tx.AddOutput(transaction.NewTxOutput(elementsAsset, zeroValue[:], []byte{}))
+	}
+	vsize, err := p.estimateVirtualSize(tx, args)
+	if err != nil {
+		return 0, err
+	}
+
+	fee := uint64(math.Ceil(float64(vsize) * satPerVByte))
+	if changeValue+currentFee <= fee {
+		return 0, fmt.Errorf(
+			"change of %d is not enough to pay fee of %d", changeValue+currentFee, fee,
+		)
+	}
+
+	newChangeValue, err := confidential.SatoshiToElementsValue(changeValue + currentFee - fee)
+	if err != nil {
+		return 0, err
+	}
+	feeValue, err := confidential.SatoshiToElementsValue(fee)
+	if err != nil {
+		return 0, err
+	}
+
+	if feeIndex < 0 {
+		if err := p.AddFeeOutput(fee); err != nil {
+			return 0, err
+		}
+	} else {
+		p.Data.UnsignedTx.Outputs[feeIndex].Value = feeValue[:]
+	}
+	p.Data.UnsignedTx.Outputs[changeIndex].Value = newChangeValue[:]
+	return fee, nil
+}
+
+// estimateVirtualSize fills the given copy of the unsigned transaction with
+// placeholders of the size of the missing scripts, witnesses, commitments and
+// proofs and returns its virtual size
+func (p *Partial) estimateVirtualSize(tx *transaction.Transaction, args EstimateArgs) (int, error) {
+	blindedIssuances, err := issuancesToBlind(tx, args.BlindedIssuances)
+	if err != nil {
+		return 0, err
+	}
+	blindedOutputs, err := outputsToBlind(tx, args.BlindedOutputs)
+	if err != nil {
+		return 0, err
+	}
+
+	numSurjectionInputs := len(tx.Inputs)
+	for index, input := range tx.Inputs {
+		scriptSig, witness, err := p.estimateInputScripts(index)
+		if err != nil {
+			return 0, fmt.Errorf("input %d: %w", index, err)
+		}
+		input.Script = scriptSig
+		input.Witness = witness
+
+		if !input.HasIssuance() {
+			continue
+		}
+		// a null amount is encoded with the single 0x00 byte
+		if len(input.Issuance.AssetAmount) > 1 {
+			numSurjectionInputs++
+			if blindedIssuances[index] {
+				input.Issuance.AssetAmount = make([]byte, commitmentSize)
+				input.IssuanceRangeProof = make([]byte, rangeProofSize)
+			}
+		}
+		if len(input.Issuance.TokenAmount) > 1 {
+			numSurjectionInputs++
+			if blindedIssuances[index] {
+				input.Issuance.TokenAmount = make([]byte, commitmentSize)
+				input.InflationRangeProof = make([]byte, rangeProofSize)
+			}
+		}
+	}
+
+	for index := range blindedOutputs {
+		output := tx.Outputs[index]
+		output.Asset = make([]byte, commitmentSize)
+		output.Value = make([]byte, commitmentSize)
+		output.Nonce = make([]byte, commitmentSize)
+		output.RangeProof = make([]byte, rangeProofSize)
+		output.SurjectionProof = make([]byte, surjectionProofSize(numSurjectionInputs))
+	}
+
+	return tx.VirtualSize(), nil
+}
+
+// outputsToBlind checks that the outputs at the given indexes can be blinded,
+// that is they exist, are not fee or burn outputs and are not blinded yet
+func outputsToBlind(tx *transaction.Transaction, indexes []int) (map[int]bool, error) {
+	outputs := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if index < 0 || index > len(tx.Outputs)-1 {
+			return nil, fmt.Errorf("output %d: index out of range", index)
+		}
+		output := tx.Outputs[index]
+		if !isBlindable(output) {
+			return nil, fmt.Errorf("output %d: fee and burn outputs can not be blinded", index)
+		}
+		if output.IsConfidential() {
+			return nil, fmt.Errorf("output %d: output is already blinded", index)
+		}
+		outputs[index] = true
+	}
+	return outputs, nil
+}
+
+// issuancesToBlind checks that the inputs at the given indexes have an
+// issuance with explicit amounts
+func issuancesToBlind(tx *transaction.Transaction, indexes []int) (map[int]bool, error) {
+	issuances := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if index < 0 || index > len(tx.Inputs)-1 {
+			return nil, fmt.Errorf("input %d: index out of range", index)
+		}
+		input := tx.Inputs[index]
+		if !input.HasIssuance() {
+			return nil, fmt.Errorf("input %d: input has no issuance", index)
+		}
+		if len(input.IssuanceRangeProof) > 0 || len(input.InflationRangeProof) > 0 {
+			return nil, fmt.Errorf("input %d: issuance is already blinded", index)
+		}
+		issuances[index] = true
+	}
+	return issuances, nil
+}
+
+// estimateInputScripts returns the final script sig and witness of the input
+// at the given index, or placeholders of the same size if not finalized yet
+func (p *Partial) estimateInputScripts(index int) ([]byte, [][]byte, error) {
+	input := p.Data.Inputs[index]
+	if input.FinalScriptSig != nil || input.FinalScriptWitness != nil {
+		witness, err := deserializeWitness(input.FinalScriptWitness)
+		if err != nil {
+			return nil, nil, err
+		}
+		return input.FinalScriptSig, witness, nil
+	}
+
+	prevout, err := p.prevout(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig := make([]byte, sigSize)
+	pubKey := make([]byte, pubKeySize)
+
+	switch {
+	case input.WitnessScript != nil:
+		stack, err := estimateMultisigStack(input.WitnessScript)
+		if err != nil {
+			return nil, nil, err
+		}
+		var scriptSig []byte
+		if input.RedeemScript != nil {
+			scriptSig, err = txscript.NewScriptBuilder().AddData(input.RedeemScript).Script()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return scriptSig, append(stack, input.WitnessScript), nil
+	case txscript.IsPayToWitnessPubKeyHash(prevout.Script):
+		return nil, [][]byte{sig, pubKey}, nil
+	case input.RedeemScript != nil && !txscript.IsWitnessProgram(input.RedeemScript):
+		stack, err := estimateMultisigStack(input.RedeemScript)
+		if err != nil {
+			return nil, nil, err
+		}
+		builder := txscript.NewScriptBuilder()
+		for _, item := range append(stack, input.RedeemScript) {
+			builder.AddData(item)
+		}
+		scriptSig, err := builder.Script()
+		return scriptSig, nil, err
+	case txscript.IsPayToScriptHash(prevout.Script):
+		// without scripts attached, p2sh inputs are expected to wrap p2wpkh
+		scriptSig, err := txscript.NewScriptBuilder().
+			AddData(make([]byte, 22)).
+			Script()
+		return scriptSig, [][]byte{sig, pubKey}, err
+	case txscript.GetScriptClass(prevout.Script) == txscript.PubKeyHashTy:
+		scriptSig, err := txscript.NewScriptBuilder().
+			AddData(sig).
+			AddData(pubKey).
+			Script()
+		return scriptSig, nil, err
+	default:
+		return nil, nil, errors.New("unable to estimate size of unsupported input type")
+	}
+}
+
+// estimateMultisigStack returns placeholders for the items required to spend
+// the given multisig script. Other scripts are not supported and must be
+// finalized before estimating the transaction size
+func estimateMultisigStack(script []byte) ([][]byte, error) {
+	if txscript.GetScriptClass(script) != txscript.MultiSigTy {
+		return nil, errors.New(
+			"unable to estimate size of non multisig script, input must be finalized",
+		)
+	}
+	_, numSigs, err := txscript.CalcMultiSigStats(script)
+	if err != nil {
+		return nil, err
+	}
+	// the empty element is consumed by the OP_CHECKMULTISIG off-by-one bug
+	stack := [][]byte{{}}
+	for i := 0; i < numSigs; i++ {
+		stack = append(stack, make([]byte, sigSize))
+	}
+	return stack, nil
+}
+
+// surjectionProofSize returns the size of a surjection proof for a
+// transaction with the given number of inputs, pseudo inputs included
+func surjectionProofSize(numInputs int) int {
+	numUsed := numInputs
+	if numUsed > maxSurjectionInputs {
+		numUsed = maxSurjectionInputs
+	}
+	return 2 + (numInputs+7)/8 + 32*(1+numUsed)
+}
@@ -0,0 +1,142 @@
+package partial
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+// AddFeeOutput adds the explicit output paying the given fee in the policy
+// asset of the selected Network. A transaction can contain only one fee output
+func (p *Partial) AddFeeOutput(value uint64) error {
+	for _, output := range p.Data.UnsignedTx.Outputs {
+		if isFeeOutput(output) {
+			return errors.New("transaction already contains a fee output")
+		}
+	}
+	return p.AddOutput(p.Network.AssetID, value, []byte{}, false)
+}
+
+// validateFeeOutput checks that the given outputs contain exactly one fee
+// output, explicit and paid in the policy asset
+func validateFeeOutput(outputs []*transaction.TxOutput, policyAsset string) error {
+	var feeOutput *transaction.TxOutput
+	for _, output := range outputs {
+		if !isFeeOutput(output) {
+			continue
+		}
+		if feeOutput != nil {
+			return errors.New("transaction contains more than one fee output")
+		}
+		feeOutput = output
+	}
+
+	if feeOutput == nil {
+		return errors.New("transaction is missing the fee output")
+	}
+	if len(feeOutput.Asset) != 33 || feeOutput.Asset[0] != 0x01 ||
+		len(feeOutput.Value) != confidential.ElementsUnconfidentialValueLength {
+		return errors.New("fee output must be unblinded")
+	}
+	if feeAsset := assetHashToHex(feeOutput.Asset[1:]); feeAsset != policyAsset {
+		return fmt.Errorf(
+			"fee output asset %s does not match network policy asset %s",
+			feeAsset, policyAsset,
+		)
+	}
+	return nil
+}
+
+// validateBalance checks, for every asset, the unblinded inputs against the
+// explicit outputs. If the fee output is present, inputs and outputs must
+// balance exactly. Otherwise outputs must not exceed inputs and only the
+// policy asset can be left over, to be spent by the fee output added later
+func validateBalance(unblindedInputs []confidential.UnblindOutputResult, outputs []*transaction.TxOutput, policyAsset string) error {
+	hasFee := false
+	for _, output := range outputs {
+		if isFeeOutput(output) {
+			hasFee = true
+			break
+		}
+	}
+	if hasFee {
+		if err := validateFeeOutput(outputs, policyAsset); err != nil {
+			return err
+		}
+	}
+
+	balances := make(map[string]int64)
+	for _, input := range unblindedInputs {
+		balances[assetHashToHex(input.Asset)] += int64(input.Value)
+	}
+	for index, output := range outputs {
+		value, err := explicitValue(output.Value)
+		if err != nil {
+			return fmt.Errorf("output %d: %w", index, err)
+		}
+		balances[assetHashToHex(output.Asset[1:])] -= int64(value)
+	}
+
+	for asset, balance := range balances {
+		switch {
+		case balance < 0:
+			return fmt.Errorf(
+				"asset %s: outputs exceed inputs by %d", asset, -balance,
+			)
+		case balance > 0 && (hasFee || asset != policyAsset):
+			return fmt.Errorf(
+				"asset %s: inputs exceed outputs by %d", asset, balance,
+			)
+		}
+	}
+	return nil
+}
+
+// validateExplicitBalance checks that inputs and outputs balance for every
+// asset if the transaction is fully explicit. Confidential transactions are
+// balanced when blinded, thus they are not checked again.
+func (p *Partial) validateExplicitBalance() error {
+	for index, input := range p.Data.UnsignedTx.Inputs {
+		prevout, err := p.prevout(index)
+		if err != nil {
+			return err
+		}
+		if prevout.IsConfidential() {
+			return nil
+		}
+		if input.HasIssuance() &&
+			(len(input.Issuance.AssetAmount) > confidential.ElementsUnconfidentialValueLength ||
+				len(input.Issuance.TokenAmount) > confidential.ElementsUnconfidentialValueLength) {
+			return nil
+		}
+	}
+	for _, output := range p.Data.UnsignedTx.Outputs {
+		if output.IsConfidential() {
+			return nil
+		}
+	}
+
+	b := &blinder{
+		data:                p.Data,
+		blindingPrivateKeys: make([][]byte, len(p.Data.Inputs)),
+	}
+	unblindedInputs, err := b.unblindInputs()
+	if err != nil {
+		return err
+	}
+	pseudoInputs, err := b.pseudoInputs()
+	if err != nil {
+		return err
+	}
+	for _, pseudoInput := range pseudoInputs {
+		unblindedInputs = append(unblindedInputs, pseudoInput.UnblindOutputResult)
+	}
+	return validateBalance(unblindedInputs, p.Data.UnsignedTx.Outputs, p.Network.AssetID)
+}
+
+// isFeeOutput returns whether the output is a fee output, with empty script
+func isFeeOutput(output *transaction.TxOutput) bool {
+	return len(output.Script) == 0
+}
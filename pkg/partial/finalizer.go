@@ -0,0 +1,165 @@
+package partial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/vulpemventures/go-elements/pset"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+// Finalize finalizes the signed input at the given index, building its final
+// script sig and/or witness from the partial signatures.
+// Only single-sig and multisig inputs are supported, use FinalizeWithStack
+// for inputs locked by custom scripts.
+func (p *Partial) Finalize(index int) error {
+	if index > (len(p.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+	return pset.Finalize(p.Data, index)
+}
+
+// FinalizeAll finalizes all the inputs of the partial transaction. Inputs
+// already finalized, for example with FinalizeWithStack, are left untouched.
+func (p *Partial) FinalizeAll() error {
+	for index, input := range p.Data.Inputs {
+		if input.FinalScriptSig != nil || input.FinalScriptWitness != nil {
+			continue
+		}
+		if err := pset.Finalize(p.Data, index); err != nil {
+			return fmt.Errorf("input %d: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// IsComplete returns whether all the inputs of the partial transaction are
+// finalized and thus the transaction can be extracted
+func (p *Partial) IsComplete() bool {
+	return p.Data.IsComplete()
+}
+
+// Extract returns the final signed transaction of a complete partial
+// transaction, ready to be broadcasted. The transaction must contain exactly
+// one unblinded fee output in the policy asset of the network and, if fully
+// explicit, balance inputs against outputs for every asset
+func (p *Partial) Extract() (*transaction.Transaction, error) {
+	if !p.IsComplete() {
+		return nil, errors.New("partial transaction is not complete")
+	}
+	if err := validateFeeOutput(p.Data.UnsignedTx.Outputs, p.Network.AssetID); err != nil {
+		return nil, err
+	}
+	if err := p.validateExplicitBalance(); err != nil {
+		return nil, err
+	}
+	return pset.Extract(p.Data)
+}
+
+// ExtractHex returns the hex encoded final signed transaction of a complete
+// partial transaction, ready to be broadcasted
+func (p *Partial) ExtractHex() (string, error) {
+	tx, err := p.Extract()
+	if err != nil {
+		return "", err
+	}
+	return tx.ToHex()
+}
+
+// FinalizeWithStack finalizes the script input at the given index with a
+// custom stack, so that inputs locked by arbitrary scripts (timelocks, HTLCs,
+// etc.) can be spent. The stack holds the items to be pushed before the
+// witness or redeem script, that is always appended as last element.
+// For P2WSH and P2SH-P2WSH inputs the stack becomes the input witness, while
+// for legacy P2SH inputs it is pushed into the final script sig.
+func (p *Partial) FinalizeWithStack(index int, stack [][]byte) error {
+	if index > (len(p.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	currInput := p.Data.Inputs[index]
+	if currInput.FinalScriptSig != nil || currInput.FinalScriptWitness != nil {
+		return errors.New("input is already finalized")
+	}
+
+	var finalScriptSig, finalScriptWitness []byte
+	switch {
+	case currInput.WitnessScript != nil:
+		witness := append(append([][]byte{}, stack...), currInput.WitnessScript)
+		serializedWitness, err := serializeWitness(witness)
+		if err != nil {
+			return err
+		}
+		finalScriptWitness = serializedWitness
+
+		if currInput.RedeemScript != nil {
+			scriptSig, err := txscript.NewScriptBuilder().
+				AddData(currInput.RedeemScript).
+				Script()
+			if err != nil {
+				return err
+			}
+			finalScriptSig = scriptSig
+		}
+	case currInput.RedeemScript != nil:
+		builder := txscript.NewScriptBuilder()
+		for _, item := range stack {
+			builder.AddData(item)
+		}
+		builder.AddData(currInput.RedeemScript)
+		scriptSig, err := builder.Script()
+		if err != nil {
+			return err
+		}
+		finalScriptSig = scriptSig
+	default:
+		return errors.New("input is missing both witness and redeem script")
+	}
+
+	finalizedInput := pset.NewPsetInput(currInput.NonWitnessUtxo, currInput.WitnessUtxo)
+	finalizedInput.FinalScriptSig = finalScriptSig
+	finalizedInput.FinalScriptWitness = finalScriptWitness
+	p.Data.Inputs[index] = *finalizedInput
+
+	return p.Data.SanityCheck()
+}
+
+// serializeWitness encodes the given witness stack in the format expected
+// for the final script witness field of a pset input
+func serializeWitness(witness [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(witness))); err != nil {
+		return nil, err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// deserializeWitness decodes a witness stack serialized as in the final
+// script witness field of a pset input
+func deserializeWitness(serializedWitness []byte) ([][]byte, error) {
+	if len(serializedWitness) == 0 {
+		return nil, nil
+	}
+	r := bytes.NewReader(serializedWitness)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	witness := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness item")
+		if err != nil {
+			return nil, err
+		}
+		witness = append(witness, item)
+	}
+	return witness, nil
+}
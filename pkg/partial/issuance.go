@@ -0,0 +1,327 @@
+package partial
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+
+	"github.com/tiero/ocean/internal/bufferutil"
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-elements/pset"
+	"github.com/vulpemventures/go-elements/transaction"
+)
+
+// IssuanceArgs defines the arguments to attach a new asset issuance to an
+// input of a Partial Signed Elements Transaction
+type IssuanceArgs struct {
+	// AssetAmount is the amount in satoshi of the asset to issue
+	AssetAmount uint64
+	// TokenAmount is the amount in satoshi of reissuance token to issue, if 0
+	// the asset can not be reissued
+	TokenAmount uint64
+	// ContractHash optionally commits the issuance to a contract, it must be
+	// 32 bytes long if defined
+	ContractHash []byte
+	// AssetScript is the output script receiving the issued asset
+	AssetScript []byte
+	// TokenScript is the output script receiving the reissuance token
+	TokenScript []byte
+	// Blinded makes the issuance confidential. The issuance amounts are then
+	// blinded with the keys passed to BlindWithIssuanceKeys, that fails if
+	// keys are missing for a blinded issuance or given for an unblinded one
+	Blinded bool
+}
+
+// AddIssuance attaches a new asset issuance to the input at the given index
+// and adds the outputs receiving the issued asset and reissuance token.
+// The hex encoded asset and token hashes derived from the prevout entropy are
+// returned.
+func (p *Partial) AddIssuance(index int, args IssuanceArgs) (asset string, token string, err error) {
+	if index > (len(p.Data.Inputs) - 1) {
+		return "", "", errors.New("index out of range")
+	}
+	if args.AssetAmount == 0 {
+		return "", "", errors.New("asset amount must be greater than 0")
+	}
+	if len(args.AssetScript) == 0 {
+		return "", "", errors.New("asset script is missing")
+	}
+	if args.TokenAmount > 0 && len(args.TokenScript) == 0 {
+		return "", "", errors.New("token script is missing")
+	}
+	if args.ContractHash != nil && len(args.ContractHash) != 32 {
+		return "", "", errors.New("contract hash must be 32 bytes long")
+	}
+	for _, input := range p.Data.Inputs {
+		if len(input.PartialSigs) > 0 {
+			return "", "", errors.New("issuance can not be added to a signed transaction")
+		}
+	}
+
+	input := p.Data.UnsignedTx.Inputs[index]
+	if input.HasIssuance() {
+		return "", "", errors.New("input already contains an issuance")
+	}
+
+	issuance, err := transaction.NewTxIssuance(args.AssetAmount, args.TokenAmount, 0, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if args.ContractHash != nil {
+		issuance.ContractHash = args.ContractHash
+	}
+	if err := issuance.GenerateEntropy(input.Hash, input.Index); err != nil {
+		return "", "", err
+	}
+
+	assetHash, err := issuance.GenerateAsset()
+	if err != nil {
+		return "", "", err
+	}
+	tokenFlag := uint(pset.NonConfidentialReissuanceTokenFlag)
+	if args.Blinded {
+		tokenFlag = pset.ConfidentialReissuanceTokenFlag
+	}
+	tokenHash, err := issuance.GenerateReissuanceToken(tokenFlag)
+	if err != nil {
+		return "", "", err
+	}
+
+	updater, err := pset.NewUpdater(p.Data)
+	if err != nil {
+		return "", "", err
+	}
+
+	updater.Data.UnsignedTx.Inputs[index].Issuance = &transaction.TxIssuance{
+		AssetBlindingNonce: issuance.TxIssuance.AssetBlindingNonce,
+		AssetEntropy:       issuance.ContractHash,
+		AssetAmount:        issuance.TxIssuance.AssetAmount,
+		TokenAmount:        issuance.TxIssuance.TokenAmount,
+	}
+
+	updater.AddOutput(transaction.NewTxOutput(
+		append([]byte{0x01}, assetHash...),
+		issuance.TxIssuance.AssetAmount,
+		args.AssetScript,
+	))
+	if args.TokenAmount > 0 {
+		updater.AddOutput(transaction.NewTxOutput(
+			append([]byte{0x01}, tokenHash...),
+			issuance.TxIssuance.TokenAmount,
+			args.TokenScript,
+		))
+	}
+
+	p.Data = updater.Data
+	if p.BlindedIssuances == nil {
+		p.BlindedIssuances = make(map[int]bool)
+	}
+	p.BlindedIssuances[index] = args.Blinded
+	return assetHashToHex(assetHash), assetHashToHex(tokenHash), nil
+}
+
+// ReissuanceArgs defines the arguments to reissue an asset by spending the
+// related reissuance token
+type ReissuanceArgs struct {
+	// AssetAmount is the amount in satoshi of the asset to reissue
+	AssetAmount uint64
+	// TokenAmount is the amount in satoshi of reissuance token held by the
+	// spent utxo, that is entirely returned to TokenScript
+	TokenAmount uint64
+	// TokenBlindingFactor is the asset blinding factor of the reissuance token
+	// utxo
+	TokenBlindingFactor []byte
+	// AssetEntropy is the entropy of the original issuance of the asset, as
+	// returned by IssuanceEntropy
+	AssetEntropy []byte
+	// AssetScript is the output script receiving the reissued asset
+	AssetScript []byte
+	// TokenScript is the output script receiving the reissuance token
+	TokenScript []byte
+}
+
+// AddReissuance adds an input spending the given confidential reissuance token
+// utxo and attaches to it the reissuance of the related asset. The outputs
+// receiving the reissued asset and the returned token are added as well, the
+// latter marked as blinded so that the token stays confidential.
+// The hex encoded asset and token hashes are returned.
+func (p *Partial) AddReissuance(hash string, index uint32, tokenUtxo *ConfidentialWitnessUtxo, args ReissuanceArgs) (asset string, token string, err error) {
+	if tokenUtxo == nil {
+		return "", "", errors.New("reissuance token utxo is missing")
+	}
+	if args.AssetAmount == 0 {
+		return "", "", errors.New("asset amount must be greater than 0")
+	}
+	if args.TokenAmount == 0 {
+		return "", "", errors.New("token amount must be greater than 0")
+	}
+	if len(args.TokenBlindingFactor) != 32 {
+		return "", "", errors.New("token blinding factor must be 32 bytes long")
+	}
+	if len(args.AssetEntropy) != 32 {
+		return "", "", errors.New("asset entropy must be 32 bytes long")
+	}
+	if len(args.AssetScript) == 0 {
+		return "", "", errors.New("asset script is missing")
+	}
+	if len(args.TokenScript) == 0 {
+		return "", "", errors.New("token script is missing")
+	}
+	for _, input := range p.Data.Inputs {
+		if len(input.PartialSigs) > 0 {
+			return "", "", errors.New("reissuance can not be added to a signed transaction")
+		}
+	}
+
+	tokenCommitment, err := hex.DecodeString(tokenUtxo.AssetCommitment)
+	if err != nil {
+		return "", "", err
+	}
+	if len(tokenCommitment) != 33 {
+		return "", "", errors.New("reissuance token utxo must be confidential")
+	}
+
+	issuance := &transaction.TxIssuanceExtended{}
+	issuance.TxIssuance.AssetEntropy = args.AssetEntropy
+	assetHash, err := issuance.GenerateAsset()
+	if err != nil {
+		return "", "", err
+	}
+
+	// the token hash depends on whether the original issuance was confidential,
+	// the one matching the utxo commitment is the token being spent
+	var tokenHash []byte
+	for _, flag := range []uint{
+		pset.NonConfidentialReissuanceTokenFlag,
+		pset.ConfidentialReissuanceTokenFlag,
+	} {
+		candidate, err := issuance.GenerateReissuanceToken(flag)
+		if err != nil {
+			return "", "", err
+		}
+		commitment, err := confidential.AssetCommitment(candidate, args.TokenBlindingFactor)
+		if err != nil {
+			return "", "", err
+		}
+		if bytes.Equal(commitment[:], tokenCommitment) {
+			tokenHash = candidate
+			break
+		}
+	}
+	if tokenHash == nil {
+		return "", "", errors.New(
+			"token utxo does not match asset entropy and token blinding factor",
+		)
+	}
+
+	assetAmount, err := confidential.SatoshiToElementsValue(args.AssetAmount)
+	if err != nil {
+		return "", "", err
+	}
+	tokenAmount, err := confidential.SatoshiToElementsValue(args.TokenAmount)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := p.AddBlindedInput(hash, index, tokenUtxo, nil); err != nil {
+		return "", "", err
+	}
+
+	updater, err := pset.NewUpdater(p.Data)
+	if err != nil {
+		return "", "", err
+	}
+
+	lastAdded := len(updater.Data.UnsignedTx.Inputs) - 1
+	updater.Data.UnsignedTx.Inputs[lastAdded].Issuance = &transaction.TxIssuance{
+		AssetBlindingNonce: args.TokenBlindingFactor,
+		AssetEntropy:       args.AssetEntropy,
+		AssetAmount:        assetAmount[:],
+		TokenAmount:        []byte{0x00},
+	}
+
+	updater.AddOutput(transaction.NewTxOutput(
+		append([]byte{0x01}, assetHash...),
+		assetAmount[:],
+		args.AssetScript,
+	))
+	updater.AddOutput(transaction.NewTxOutput(
+		append([]byte{0x00}, tokenHash...),
+		tokenAmount[:],
+		args.TokenScript,
+	))
+
+	p.Data = updater.Data
+	return assetHashToHex(assetHash), assetHashToHex(tokenHash), nil
+}
+
+// IssuanceEntropy returns the entropy of the asset issued by the input
+// spending the given outpoint, committed to the optional contract hash. It is
+// required to reissue the asset.
+func IssuanceEntropy(hash string, index uint32, contractHash []byte) ([]byte, error) {
+	if contractHash == nil {
+		contractHash = make([]byte, 32)
+	}
+	if len(contractHash) != 32 {
+		return nil, errors.New("contract hash must be 32 bytes long")
+	}
+
+	inputHash, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, err
+	}
+	issuance := transaction.NewTxIssuanceFromContractHash(contractHash)
+	if err := issuance.GenerateEntropy(bufferutil.ReverseBytes(inputHash), index); err != nil {
+		return nil, err
+	}
+	return issuance.TxIssuance.AssetEntropy, nil
+}
+
+// issuanceAssets returns the asset and reissuance token hashes of the issuance
+// of the given input. For a new issuance the entropy is derived from the
+// outpoint and the contract hash stored in place of the entropy, while a
+// reissuance, recognized by its non-zero blinding nonce, carries the entropy
+// of the original issuance
+func issuanceAssets(input *transaction.TxInput, blinded bool) (asset []byte, token []byte, err error) {
+	issuance := &transaction.TxIssuanceExtended{}
+	if isReissuance(input.Issuance) {
+		issuance.TxIssuance.AssetEntropy = append([]byte{}, input.Issuance.AssetEntropy...)
+	} else {
+		issuance.ContractHash = append([]byte{}, input.Issuance.AssetEntropy...)
+		if err := issuance.GenerateEntropy(input.Hash, input.Index); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	asset, err = issuance.GenerateAsset()
+	if err != nil {
+		return nil, nil, err
+	}
+	tokenFlag := uint(pset.NonConfidentialReissuanceTokenFlag)
+	if blinded {
+		tokenFlag = pset.ConfidentialReissuanceTokenFlag
+	}
+	token, err = issuance.GenerateReissuanceToken(tokenFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return asset, token, nil
+}
+
+// isReissuance returns whether the issuance reissues an existing asset
+func isReissuance(issuance *transaction.TxIssuance) bool {
+	for _, b := range issuance.AssetBlindingNonce {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// assetHashToHex returns the hex encoding of the given asset hash in the
+// reversed byte order it is displayed with
+func assetHashToHex(hash []byte) string {
+	reversed := make([]byte, len(hash))
+	copy(reversed, hash)
+	return hex.EncodeToString(bufferutil.ReverseBytes(reversed))
+}
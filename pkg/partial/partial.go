@@ -1,11 +1,14 @@
 package partial
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
 	"github.com/tiero/ocean/internal/bufferutil"
 	"github.com/tiero/ocean/pkg/keypair"
 	"github.com/vulpemventures/go-elements/confidential"
@@ -19,6 +22,12 @@ import (
 type Partial struct {
 	Data    *pset.Pset
 	Network *network.Network
+	// BlindingData holds, by output index, the data the outputs have been
+	// blinded with, to verify them afterwards. It is not serialized
+	BlindingData map[int]*OutputBlindingData
+	// BlindedIssuances holds, by input index, whether the issuances added
+	// with AddIssuance are to be blinded. It is not serialized
+	BlindedIssuances map[int]bool
 }
 
 // WitnessUtxo defines a witness utxo
@@ -38,62 +47,128 @@ type ConfidentialWitnessUtxo struct {
 	SurjectionProof []byte
 }
 
+// UnblindedInput defines the revealed asset, value and blinding factors of
+// the confidential utxo spent by an input
+type UnblindedInput struct {
+	Asset        string
+	Value        uint64
+	AssetBlinder []byte
+	ValueBlinder []byte
+}
+
+// BlindArgs defines the arguments to blind a transaction. Confidential inputs
+// are unblinded with the private blinding keys or, if owned by other parties,
+// described by the unblinded inputs. Both are parallel to the inputs and
+// entries of the latter take precedence.
+// Outputs are blinded either with BlindingPublicKeys, parallel to the outputs,
+// or with OutputBlindingKeys, mapping the index of only the outputs to blind
+// to their blinding public key. The latter must include all the outputs added
+// as blinded.
+type BlindArgs struct {
+	BlindingPrivateKeys  [][]byte
+	UnblindedInputs      []*UnblindedInput
+	BlindingPublicKeys   [][]byte
+	OutputBlindingKeys   map[int][]byte
+	IssuanceBlindingKeys []pset.IssuanceBlindingPrivateKeys
+}
+
 //NewPartial returns a Partial instance with an empty pset in Partial.Data and the selected Network
 func NewPartial(net *network.Network) *Partial {
-	currentNetwork := &network.Liquid
-	if net != nil {
-		currentNetwork = net
-	}
 	emptyPset, _ := pset.New([]*transaction.TxInput{}, []*transaction.TxOutput{}, 2, 0)
-	return &Partial{Data: emptyPset, Network: currentNetwork}
+	return fromPset(emptyPset, net)
 }
 
-//AddInput adds an utxo to a Partial Signed Elements Transaction
+// AddInput adds an utxo to a Partial Signed Elements Transaction.
+// A legacy (non-segwit) utxo can be added by passing the serialized previous
+// transaction as nonWitnessUtxo in place of witnessUtxo. nonWitnessUtxo keeps
+// the raw bytes type of the existing signature, use AddNonWitnessInputHex to
+// add the previous transaction as hex
 func (p *Partial) AddInput(hash string, index uint32, witnessUtxo *WitnessUtxo, nonWitnessUtxo []byte) error {
-	updater, err := pset.NewUpdater(p.Data)
-	if err != nil {
-		return err
+	if witnessUtxo != nil {
+		elementsValue, err := confidential.SatoshiToElementsValue(witnessUtxo.Value)
+		if err != nil {
+			return err
+		}
+		elementsAsset, err := AssetHashToBytes(witnessUtxo.Asset, false)
+		if err != nil {
+			return err
+		}
+		prevout := transaction.NewTxOutput(elementsAsset, elementsValue[:], witnessUtxo.Script)
+		return p.addInput(hash, index, prevout, nil)
 	}
 
-	inputHash, err := hex.DecodeString(hash)
-	if err != nil {
-		return err
+	if nonWitnessUtxo != nil {
+		prevTx, err := transaction.NewTxFromBuffer(bytes.NewBuffer(nonWitnessUtxo))
+		if err != nil {
+			return fmt.Errorf("invalid previous transaction: %w", err)
+		}
+		return p.addInput(hash, index, nil, prevTx)
 	}
-	inputHash = bufferutil.ReverseBytes(inputHash)
-	inputIndex := index
-	input := transaction.NewTxInput(inputHash, inputIndex)
-
-	updater.AddInput(input)
-	lastAdded := len(updater.Data.Inputs) - 1
 
-	err = updater.AddInSighashType(txscript.SigHashAll, lastAdded)
-	if err != nil {
-		return err
-	}
+	return errors.New("Either witnessUtxo or nonWitnessUtxo is missing")
+}
 
+//AddBlindedInput adds an utxo to a Partial Signed Elements Transaction
+func (p *Partial) AddBlindedInput(hash string, index uint32, witnessUtxo *ConfidentialWitnessUtxo, nonWitnessUtxo []byte) error {
 	if witnessUtxo != nil {
-		elementsValue, err := confidential.SatoshiToElementsValue(witnessUtxo.Value)
+		valueCommitment, err := hex.DecodeString(witnessUtxo.ValueCommitment)
 		if err != nil {
 			return err
 		}
-		elementsAsset, err := AssetHashToBytes(witnessUtxo.Asset, false)
+		assetCommitment, err := hex.DecodeString(witnessUtxo.AssetCommitment)
 		if err != nil {
 			return err
 		}
-		witnessUtxo := transaction.NewTxOutput(elementsAsset, elementsValue[:], witnessUtxo.Script)
-		updater.AddInWitnessUtxo(witnessUtxo, lastAdded)
-		return nil
+		prevout := &transaction.TxOutput{
+			Asset:           assetCommitment,
+			Value:           valueCommitment,
+			Script:          witnessUtxo.Script,
+			Nonce:           witnessUtxo.Nonce,
+			RangeProof:      witnessUtxo.RangeProof,
+			SurjectionProof: witnessUtxo.SurjectionProof,
+		}
+		return p.addInput(hash, index, prevout, nil)
 	}
 
 	if nonWitnessUtxo != nil {
-		return errors.New("Not yet implemented. Only segwit inputs supported")
+		prevTx, err := transaction.NewTxFromBuffer(bytes.NewBuffer(nonWitnessUtxo))
+		if err != nil {
+			return fmt.Errorf("invalid previous transaction: %w", err)
+		}
+		return p.addInput(hash, index, nil, prevTx)
 	}
 
 	return errors.New("Either witnessUtxo or nonWitnessUtxo is missing")
 }
 
-//AddBlindedInput adds an utxo to a Partial Signed Elements Transaction
-func (p *Partial) AddBlindedInput(hash string, index uint32, witnessUtxo *ConfidentialWitnessUtxo, nonWitnessUtxo []byte) error {
+// AddNonWitnessInput adds a legacy utxo to a Partial Signed Elements Transaction
+// given the full previous transaction it belongs to
+func (p *Partial) AddNonWitnessInput(hash string, index uint32, prevTx *transaction.Transaction) error {
+	if prevTx == nil {
+		return errors.New("previous transaction is missing")
+	}
+	return p.addInput(hash, index, nil, prevTx)
+}
+
+// AddNonWitnessInputHex adds a legacy utxo to a Partial Signed Elements
+// Transaction given the hex of the full previous transaction it belongs to
+func (p *Partial) AddNonWitnessInputHex(hash string, index uint32, prevTxHex string) error {
+	serializedPrevTx, err := hex.DecodeString(prevTxHex)
+	if err != nil {
+		return fmt.Errorf("invalid previous transaction hex: %w", err)
+	}
+	prevTx, err := transaction.NewTxFromBuffer(bytes.NewBuffer(serializedPrevTx))
+	if err != nil {
+		return fmt.Errorf("invalid previous transaction: %w", err)
+	}
+	return p.addInput(hash, index, nil, prevTx)
+}
+
+// addInput adds the input spending the given prevout to the pset. Exactly one
+// of witnessUtxo and nonWitnessUtxo is expected to be not nil. In the latter
+// case, the outpoint is validated against the previous transaction before
+// the input is added.
+func (p *Partial) addInput(hash string, index uint32, witnessUtxo *transaction.TxOutput, nonWitnessUtxo *transaction.Transaction) error {
 	updater, err := pset.NewUpdater(p.Data)
 	if err != nil {
 		return err
@@ -104,8 +179,18 @@ func (p *Partial) AddBlindedInput(hash string, index uint32, witnessUtxo *Confid
 		return err
 	}
 	inputHash = bufferutil.ReverseBytes(inputHash)
-	inputIndex := index
-	input := transaction.NewTxInput(inputHash, inputIndex)
+
+	if nonWitnessUtxo != nil {
+		prevTxHash := nonWitnessUtxo.TxHash()
+		if !bytes.Equal(prevTxHash[:], inputHash) {
+			return errors.New("previous transaction hash does not match input hash")
+		}
+		if int(index) >= len(nonWitnessUtxo.Outputs) {
+			return errors.New("input index out of range of previous transaction outputs")
+		}
+	}
+
+	input := transaction.NewTxInput(inputHash, index)
 
 	updater.AddInput(input)
 	lastAdded := len(updater.Data.Inputs) - 1
@@ -115,33 +200,116 @@ func (p *Partial) AddBlindedInput(hash string, index uint32, witnessUtxo *Confid
 		return err
 	}
 
-	if witnessUtxo != nil {
-		valueCommitment, err := hex.DecodeString(witnessUtxo.ValueCommitment)
+	if nonWitnessUtxo != nil {
+		err = updater.AddInNonWitnessUtxo(nonWitnessUtxo, lastAdded)
+	} else {
+		err = updater.AddInWitnessUtxo(witnessUtxo, lastAdded)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.Data = updater.Data
+	return nil
+}
+
+// AddInWitnessScript attaches the witness script to the P2WSH or P2SH-P2WSH
+// input at the given index. For a nested input the P2WSH redeem script is
+// derived from the witness script and attached as well
+func (p *Partial) AddInWitnessScript(index int, witnessScript []byte) error {
+	updater, err := pset.NewUpdater(p.Data)
+	if err != nil {
+		return err
+	}
+
+	if index > (len(updater.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	prevout, err := p.prevout(index)
+	if err != nil {
+		return err
+	}
+
+	witnessProgram := sha256.Sum256(witnessScript)
+	p2wshScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(witnessProgram[:]).
+		Script()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case txscript.IsPayToWitnessScriptHash(prevout.Script):
+		if !bytes.Equal(prevout.Script, p2wshScript) {
+			return errors.New("witness script does not match prevout script")
+		}
+	case txscript.IsPayToScriptHash(prevout.Script):
+		p2shScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(p2wshScript)).
+			AddOp(txscript.OP_EQUAL).
+			Script()
 		if err != nil {
 			return err
 		}
-		assetCommitment, err := hex.DecodeString(witnessUtxo.AssetCommitment)
+		if !bytes.Equal(prevout.Script, p2shScript) {
+			return errors.New("witness script does not match prevout script")
+		}
+		err = updater.AddInRedeemScript(p2wshScript, index)
 		if err != nil {
 			return err
 		}
-		witnessUtxo := &transaction.TxOutput{
-			Asset:           assetCommitment,
-			Value:           valueCommitment,
-			Script:          witnessUtxo.Script,
-			Nonce:           witnessUtxo.Nonce,
-			RangeProof:      witnessUtxo.RangeProof,
-			SurjectionProof: witnessUtxo.SurjectionProof,
-		}
-		updater.AddInWitnessUtxo(witnessUtxo, lastAdded)
-		return nil
+	default:
+		return errors.New("prevout script is neither p2wsh nor p2sh")
 	}
 
-	if nonWitnessUtxo != nil {
-		return errors.New("Not yet implemented. Only segwit inputs supported")
+	err = updater.AddInWitnessScript(witnessScript, index)
+	if err != nil {
+		return err
 	}
 
-	return errors.New("Either witnessUtxo or nonWitnessUtxo is missing")
+	p.Data = updater.Data
+	return nil
+}
 
+// AddInRedeemScript attaches the redeem script to the legacy P2SH input at
+// the given index
+func (p *Partial) AddInRedeemScript(index int, redeemScript []byte) error {
+	updater, err := pset.NewUpdater(p.Data)
+	if err != nil {
+		return err
+	}
+
+	if index > (len(updater.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	prevout, err := p.prevout(index)
+	if err != nil {
+		return err
+	}
+
+	p2shScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).
+		Script()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(prevout.Script, p2shScript) {
+		return errors.New("redeem script does not match prevout script")
+	}
+
+	err = updater.AddInRedeemScript(redeemScript, index)
+	if err != nil {
+		return err
+	}
+
+	p.Data = updater.Data
+	return nil
 }
 
 // AddOutput adds an output to a Partial Signed Elements Transaction
@@ -166,27 +334,157 @@ func (p *Partial) AddOutput(asset string, value uint64, script []byte, blinded b
 	return nil
 }
 
-//BlindWithKeys unblinds all the inputs and blinds all the outputs with the provided arrays of keys
+// AddBurnOutput adds an output provably burning the given amount of asset.
+// The output is locked by an OP_RETURN script and is never blinded
+func (p *Partial) AddBurnOutput(asset string, value uint64) error {
+	if value == 0 {
+		return errors.New("burn amount must be greater than 0")
+	}
+	script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).Script()
+	if err != nil {
+		return err
+	}
+	return p.AddOutput(asset, value, script, false)
+}
+
+//BlindWithKeys unblinds all the inputs and blinds all the outputs with the provided arrays of keys.
+//Fee and burn outputs are left explicit and the related public keys are ignored
 func (p *Partial) BlindWithKeys(blindingPrivateKeys [][]byte, blindingPublicKeys [][]byte) error {
-	blinder, err := pset.NewBlinder(
-		p.Data,
-		blindingPrivateKeys,
-		blindingPublicKeys,
-		nil,
-		nil)
+	return p.BlindWithIssuanceKeys(blindingPrivateKeys, blindingPublicKeys, nil)
+}
+
+// BlindWithIssuanceKeys unblinds all the inputs and blinds all the outputs
+// with the provided arrays of keys, like BlindWithKeys. In addition, the
+// amounts of the confidential issuances are blinded with the issuance keys,
+// provided in an array parallel to the inputs. Issuances of inputs without
+// keys are left explicit
+func (p *Partial) BlindWithIssuanceKeys(blindingPrivateKeys [][]byte, blindingPublicKeys [][]byte, issuanceBlindingKeys []pset.IssuanceBlindingPrivateKeys) error {
+	return p.Blind(BlindArgs{
+		BlindingPrivateKeys:  blindingPrivateKeys,
+		BlindingPublicKeys:   blindingPublicKeys,
+		IssuanceBlindingKeys: issuanceBlindingKeys,
+	})
+}
+
+// Blind unblinds all the inputs and blinds the outputs and the issuances as
+// defined by the given args. Fee and burn outputs are always left explicit
+func (p *Partial) Blind(args BlindArgs) error {
+	b := &blinder{
+		data:                 p.Data,
+		blindingPrivateKeys:  args.BlindingPrivateKeys,
+		unblindedInputs:      args.UnblindedInputs,
+		blindingPublicKeys:   args.BlindingPublicKeys,
+		outputBlindingKeys:   args.OutputBlindingKeys,
+		issuanceBlindingKeys: args.IssuanceBlindingKeys,
+		blindedIssuances:     p.BlindedIssuances,
+		policyAsset:          p.Network.AssetID,
+	}
+	if err := b.blind(); err != nil {
+		return err
+	}
+
+	if p.BlindingData == nil {
+		p.BlindingData = make(map[int]*OutputBlindingData)
+	}
+	for index, data := range b.blindingData {
+		p.BlindingData[index] = data
+	}
+	return nil
+}
+
+// BlindingPrivateKeys returns the private blinding keys of the inputs, to be
+// passed to BlindWithKeys, looked up by the script of the spent outputs. Keys
+// of unconfidential prevouts are nil
+func (p *Partial) BlindingPrivateKeys(lookup func(script []byte) ([]byte, error)) ([][]byte, error) {
+	keys := make([][]byte, 0, len(p.Data.Inputs))
+	for index := range p.Data.Inputs {
+		prevout, err := p.prevout(index)
+		if err != nil {
+			return nil, err
+		}
+		if !prevout.IsConfidential() {
+			keys = append(keys, nil)
+			continue
+		}
+		key, err := lookup(prevout.Script)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", index, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// BlindingPublicKeys returns the public blinding keys of the outputs, to be
+// passed to BlindWithKeys, looked up by their script. Keys of fee and burn
+// outputs are nil
+func (p *Partial) BlindingPublicKeys(lookup func(script []byte) ([]byte, error)) ([][]byte, error) {
+	keys := make([][]byte, 0, len(p.Data.Outputs))
+	for index, output := range p.Data.UnsignedTx.Outputs {
+		if !isBlindable(output) {
+			keys = append(keys, nil)
+			continue
+		}
+		key, err := lookup(output.Script)
+		if err != nil {
+			return nil, fmt.Errorf("output %d: %w", index, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// AddInSighashType sets the sighash type the input at the given index must be
+// signed with. Inputs are added with SIGHASH_ALL by default.
+func (p *Partial) AddInSighashType(index int, sighashType txscript.SigHashType) error {
+	if err := validateSighashType(sighashType); err != nil {
+		return err
+	}
+
+	updater, err := pset.NewUpdater(p.Data)
 	if err != nil {
 		return err
 	}
-	err = blinder.Blind()
+
+	if index > (len(updater.Data.Inputs) - 1) {
+		return errors.New("index out of range")
+	}
+
+	currInput := updater.Data.Inputs[index]
+	if len(currInput.PartialSigs) > 0 && currInput.SighashType != sighashType {
+		return errors.New("input is already signed with a different sighash type")
+	}
+
+	err = updater.AddInSighashType(sighashType, index)
 	if err != nil {
 		return err
 	}
 
+	p.Data = updater.Data
 	return nil
 }
 
-//SignWithPrivateKey signs a witness input with a provided EC private key
+// SignWithPrivateKey signs a witness or legacy input with a provided EC private key
+// using the sighash type of the input, SIGHASH_ALL if not set
 func (p *Partial) SignWithPrivateKey(index int, keyPair *keypair.KeyPair) error {
+	return p.sign(index, keyPair, 0)
+}
+
+// SignWithPrivateKeyAndSighash signs a witness or legacy input with a provided
+// EC private key and sighash type. The sighash type of an input not signed yet
+// is set, unless a type other than SIGHASH_ALL was required with
+// AddInSighashType. An error is returned if the input requires a different
+// sighash type
+func (p *Partial) SignWithPrivateKeyAndSighash(index int, keyPair *keypair.KeyPair, sighashType txscript.SigHashType) error {
+	if err := validateSighashType(sighashType); err != nil {
+		return err
+	}
+	return p.sign(index, keyPair, sighashType)
+}
+
+// sign signs the input at the given index with the provided EC private key.
+// If sighashType is 0 the one of the input is used
+func (p *Partial) sign(index int, keyPair *keypair.KeyPair, sighashType txscript.SigHashType) error {
 	updater, err := pset.NewUpdater(p.Data)
 	if err != nil {
 		return err
@@ -196,26 +494,103 @@ func (p *Partial) SignWithPrivateKey(index int, keyPair *keypair.KeyPair) error
 		return errors.New("index out of range")
 	}
 
+	prevout, err := p.prevout(index)
+	if err != nil {
+		return err
+	}
+	script := prevout.Script
+	if len(script) == 0 {
+		return errors.New("prevout script is empty")
+	}
+
 	currInput := updater.Data.Inputs[index]
-	if currInput.NonWitnessUtxo != nil {
-		return errors.New("Only segwit input supported")
+
+	switch {
+	case sighashType == 0 && currInput.SighashType == 0:
+		sighashType = txscript.SigHashAll
+	case sighashType == 0:
+		sighashType = currInput.SighashType
+	case currInput.SighashType == 0,
+		currInput.SighashType == txscript.SigHashAll && len(currInput.PartialSigs) == 0:
+		// inputs are added with SIGHASH_ALL, that is replaced by the sighash
+		// type of the first signature
+		err = updater.AddInSighashType(sighashType, index)
+		if err != nil {
+			return err
+		}
+	case currInput.SighashType != sighashType:
+		return fmt.Errorf(
+			"sighash type %d does not match the one of the input %d",
+			sighashType, currInput.SighashType,
+		)
+	}
+
+	if sighashType&0x1f == txscript.SigHashSingle && index >= len(updater.Data.UnsignedTx.Outputs) {
+		return errors.New("SIGHASH_SINGLE input has no output at the same index")
+	}
+
+	// p2wsh or p2sh-p2wsh input, the witness script is the script code
+	if currInput.WitnessScript != nil {
+		witHash := updater.Data.UnsignedTx.HashForWitnessV0(index, currInput.WitnessScript, prevout.Value[:], sighashType)
+		sig, err := keyPair.PrivateKey.Sign(witHash[:])
+		if err != nil {
+			return fmt.Errorf("PrivateKey Sign: %w", err)
+		}
+
+		sigWithHashType := append(sig.Serialize(), byte(sighashType))
+		_, err = updater.Sign(index, sigWithHashType, keyPair.PublicKey.SerializeCompressed(), nil, nil)
+		if err != nil {
+			return fmt.Errorf("Updater Sign: %w", err)
+		}
+
+		p.Data = updater.Data
+		return nil
+	}
+
+	// legacy p2pkh or p2sh input
+	if script[0] == txscript.OP_DUP || (currInput.RedeemScript != nil && !txscript.IsWitnessProgram(currInput.RedeemScript)) {
+		// the underlying legacy sighash does not commit correctly to inputs
+		// with SIGHASH_ANYONECANPAY, so we refuse to produce such signatures
+		if sighashType&txscript.SigHashAnyOneCanPay != 0 {
+			return errors.New("SIGHASH_ANYONECANPAY is not supported for legacy inputs")
+		}
+		scriptCode := script
+		if currInput.RedeemScript != nil {
+			scriptCode = currInput.RedeemScript
+		}
+		hash, err := updater.Data.UnsignedTx.HashForSignature(index, scriptCode, sighashType)
+		if err != nil {
+			return err
+		}
+		sig, err := keyPair.PrivateKey.Sign(hash[:])
+		if err != nil {
+			return fmt.Errorf("PrivateKey Sign: %w", err)
+		}
+
+		sigWithHashType := append(sig.Serialize(), byte(sighashType))
+		_, err = updater.Sign(index, sigWithHashType, keyPair.PublicKey.SerializeCompressed(), nil, nil)
+		if err != nil {
+			return fmt.Errorf("Updater Sign: %w", err)
+		}
+
+		p.Data = updater.Data
+		return nil
 	}
 
 	var witHash [32]byte
-	script := currInput.WitnessUtxo.Script
 	prevoutPayment, err := payment.FromScript(script, p.Network, nil)
 	if err != nil {
 		return err
 	}
 	// legacy Script
 	legacyScript := append(append([]byte{0x76, 0xa9, 0x14}, prevoutPayment.Hash...), []byte{0x88, 0xac}...)
-	witHash = updater.Data.UnsignedTx.HashForWitnessV0(index, legacyScript, currInput.WitnessUtxo.Value[:], txscript.SigHashAll)
+	witHash = updater.Data.UnsignedTx.HashForWitnessV0(index, legacyScript, prevout.Value[:], sighashType)
 	sig, err := keyPair.PrivateKey.Sign(witHash[:])
 	if err != nil {
 		return fmt.Errorf("PrivateKey Sign: %w", err)
 	}
 
-	sigWithHashType := append(sig.Serialize(), byte(txscript.SigHashAll))
+	sigWithHashType := append(sig.Serialize(), byte(sighashType))
 
 	if script[0] == txscript.OP_0 {
 		_, err = updater.Sign(index, sigWithHashType, keyPair.PublicKey.SerializeCompressed(), nil, nil)
@@ -236,6 +611,12 @@ func (p *Partial) SignWithPrivateKey(index int, keyPair *keypair.KeyPair) error
 	return nil
 }
 
+// prevout returns the output spent by the input at the given index, taken
+// either from its witness utxo or from its full previous transaction
+func (p *Partial) prevout(index int) (*transaction.TxOutput, error) {
+	return prevoutOf(p.Data, index)
+}
+
 //AssetHashToBytes reverse decode from hex string and reverse it adding a 0x01 byte for ublinded asset
 func AssetHashToBytes(hash string, blinded bool) ([]byte, error) {
 	firstByte := byte(0x01)
@@ -249,3 +630,14 @@ func AssetHashToBytes(hash string, blinded bool) ([]byte, error) {
 	assetBytes = append([]byte{firstByte}, bufferutil.ReverseBytes(assetBytes)...)
 	return assetBytes, nil
 }
+
+// validateSighashType checks that the given sighash type is one of ALL, NONE
+// or SINGLE, optionally combined with ANYONECANPAY
+func validateSighashType(sighashType txscript.SigHashType) error {
+	switch sighashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashAll, txscript.SigHashNone, txscript.SigHashSingle:
+		return nil
+	default:
+		return fmt.Errorf("invalid sighash type %d", sighashType)
+	}
+}
@@ -2,19 +2,27 @@ package partial
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
+	"github.com/tiero/ocean/internal/testutil"
 	"github.com/tiero/ocean/pkg/coinselect"
 	"github.com/tiero/ocean/pkg/explorer/blockstream"
 	"github.com/tiero/ocean/pkg/keypair"
+	"github.com/vulpemventures/go-elements/confidential"
 	"github.com/vulpemventures/go-elements/network"
 	"github.com/vulpemventures/go-elements/payment"
 	"github.com/vulpemventures/go-elements/pset"
@@ -41,6 +49,1534 @@ func TestNewPartial(t *testing.T) {
 
 }
 
+func TestSignNonWitnessInput(t *testing.T) {
+	p := NewPartial(&network.Regtest)
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKeyPair, err := keypair.FromPrivateKey(bobHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	bob := payment.FromPublicKey(bobKeyPair.PublicKey, &network.Regtest, nil)
+
+	prevTx := newPrevTx(t, alice.Script, 100000000)
+	serializedPrevTx, err := prevTx.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevTxHash := prevTx.TxHash()
+
+	if err := p.AddInput(prevTxHash.String(), 1, nil, serializedPrevTx); err == nil {
+		t.Fatal("Should have failed with out of range prevout index")
+	}
+	if err := p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, nil, serializedPrevTx); err == nil {
+		t.Fatal("Should have failed with mismatching prevout hash")
+	}
+	if len(p.Data.Inputs) != 0 {
+		t.Fatalf("Got %d inputs, expected 0", len(p.Data.Inputs))
+	}
+
+	if err := p.AddInput(prevTxHash.String(), 0, nil, serializedPrevTx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddOutput(network.Regtest.AssetID, 99999500, bob.Script, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SignWithPrivateKey(0, kp); err != nil {
+		t.Fatal(err)
+	}
+
+	partialSig := p.Data.Inputs[0].PartialSigs[0]
+	sig, err := btcec.ParseDERSignature(partialSig.Signature[:len(partialSig.Signature)-1], btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := p.Data.UnsignedTx.HashForSignature(0, alice.Script, txscript.SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify(hash[:], kp.PublicKey) {
+		t.Fatal("Invalid signature for legacy input")
+	}
+
+	if p.IsComplete() {
+		t.Fatal("Should not be complete before finalization")
+	}
+	if _, err := p.Extract(); err == nil {
+		t.Fatal("Should have failed extracting an incomplete transaction")
+	}
+	if err := p.FinalizeAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsComplete() {
+		t.Fatal("Should be complete after finalization")
+	}
+	finalScriptSig := p.Data.Inputs[0].FinalScriptSig
+	if len(finalScriptSig) == 0 {
+		t.Fatal("Got empty final script sig, expected not empty")
+	}
+
+	finalTx, err := p.Extract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(finalTx.Inputs[0].Script, finalScriptSig) {
+		t.Fatal("Extracted transaction input does not contain the final script sig")
+	}
+	txHex, err := p.ExtractHex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transaction.NewTxFromHex(txHex); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddNonWitnessInputHex(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	prevTx := newPrevTx(t, alice.Script, 100000000)
+	prevTxHex, err := prevTx.ToHex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevTxHash := prevTx.TxHash()
+
+	p := NewPartial(&network.Regtest)
+	err = p.AddNonWitnessInputHex(prevTxHash.String(), 0, "zz")
+	if err == nil || !strings.Contains(err.Error(), "invalid previous transaction hex") {
+		t.Fatalf("Should have failed with invalid hex, got %v", err)
+	}
+	if err := p.AddNonWitnessInputHex(prevTxHash.String(), 0, prevTxHex); err != nil {
+		t.Fatal(err)
+	}
+	if p.Data.Inputs[0].NonWitnessUtxo.TxHash() != prevTxHash {
+		t.Fatal("Got unexpected non witness utxo")
+	}
+}
+
+func TestSignAndFinalizeWitnessScriptInput(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKeyPair, err := keypair.FromPrivateKey(bobHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := payment.FromPublicKey(bobKeyPair.PublicKey, &network.Regtest, nil)
+
+	// hash time locked script spendable by alice with the preimage
+	preimage := []byte("ocean")
+	preimageHash := sha256.Sum256(preimage)
+	witnessScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_SHA256).
+		AddData(preimageHash[:]).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddData(kp.PublicKey.SerializeCompressed()).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redeem, err := payment.FromScript(witnessScript, &network.Regtest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htlc, err := payment.FromPayment(redeem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedHtlc, err := payment.FromPayment(&payment.Payment{Script: htlc.WitnessScript, Network: &network.Regtest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		script        []byte
+		wantScriptSig bool
+	}{
+		{"p2wsh", htlc.WitnessScript, false},
+		{"p2sh-p2wsh", nestedHtlc.Script, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: tt.script,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 99999500, bob.Script, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.AddInWitnessScript(0, bob.Script); err == nil {
+				t.Fatal("Should have failed with mismatching witness script")
+			}
+			if err := p.AddInWitnessScript(0, witnessScript); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SignWithPrivateKey(0, kp); err != nil {
+				t.Fatal(err)
+			}
+
+			partialSig := p.Data.Inputs[0].PartialSigs[0]
+			sig, err := btcec.ParseDERSignature(partialSig.Signature[:len(partialSig.Signature)-1], btcec.S256())
+			if err != nil {
+				t.Fatal(err)
+			}
+			value := p.Data.Inputs[0].WitnessUtxo.Value
+			hash := p.Data.UnsignedTx.HashForWitnessV0(0, witnessScript, value, txscript.SigHashAll)
+			if !sig.Verify(hash[:], kp.PublicKey) {
+				t.Fatal("Invalid signature for witness script input")
+			}
+
+			if err := p.FinalizeWithStack(0, [][]byte{partialSig.Signature, preimage}); err != nil {
+				t.Fatal(err)
+			}
+			wantWitness, err := serializeWitness([][]byte{partialSig.Signature, preimage, witnessScript})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(p.Data.Inputs[0].FinalScriptWitness, wantWitness) {
+				t.Fatal("Got unexpected final script witness")
+			}
+			if gotScriptSig := len(p.Data.Inputs[0].FinalScriptSig) > 0; gotScriptSig != tt.wantScriptSig {
+				t.Fatalf("Got final script sig %v, expected %v", gotScriptSig, tt.wantScriptSig)
+			}
+		})
+	}
+}
+
+func TestSignWithSighashType(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKeyPair, err := keypair.FromPrivateKey(bobHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	bob := payment.FromPublicKey(bobKeyPair.PublicKey, &network.Regtest, nil)
+	sighashType := txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+
+	p := NewPartial(&network.Regtest)
+	err = p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, &WitnessUtxo{
+		Asset:  network.Regtest.AssetID,
+		Value:  100000000,
+		Script: alice.WitnessScript,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddOutput(network.Regtest.AssetID, 99999500, bob.Script, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.AddInSighashType(0, txscript.SigHashType(0x04)); err == nil {
+		t.Fatal("Should have failed with invalid sighash type")
+	}
+	if err := p.AddInSighashType(0, sighashType); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SignWithPrivateKeyAndSighash(0, kp, txscript.SigHashNone); err == nil {
+		t.Fatal("Should have failed with mismatching sighash type")
+	}
+	if err := p.SignWithPrivateKey(0, kp); err != nil {
+		t.Fatal(err)
+	}
+
+	partialSig := p.Data.Inputs[0].PartialSigs[0]
+	if gotSighashType := txscript.SigHashType(partialSig.Signature[len(partialSig.Signature)-1]); gotSighashType != sighashType {
+		t.Fatalf("Got sighash type %d, expected %d", gotSighashType, sighashType)
+	}
+	sig, err := btcec.ParseDERSignature(partialSig.Signature[:len(partialSig.Signature)-1], btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := p.Data.Inputs[0].WitnessUtxo.Value
+	hash := p.Data.UnsignedTx.HashForWitnessV0(0, alice.Script, value, sighashType)
+	if !sig.Verify(hash[:], kp.PublicKey) {
+		t.Fatal("Invalid signature for SIGHASH_SINGLE|ANYONECANPAY input")
+	}
+
+	if err := p.AddInSighashType(0, txscript.SigHashAll); err == nil {
+		t.Fatal("Should have failed changing sighash type of a signed input")
+	}
+	if err := p.FinalizeAll(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignNewInputWithSighashType(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKeyPair, err := keypair.FromPrivateKey(bobHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	bob := payment.FromPublicKey(bobKeyPair.PublicKey, &network.Regtest, nil)
+
+	sighashTypes := []txscript.SigHashType{
+		txscript.SigHashNone,
+		txscript.SigHashSingle,
+		txscript.SigHashAll | txscript.SigHashAnyOneCanPay,
+	}
+	for _, sighashType := range sighashTypes {
+		p := NewPartial(&network.Regtest)
+		err = p.AddInput(hex.EncodeToString(make([]byte, 32)), 0, &WitnessUtxo{
+			Asset:  network.Regtest.AssetID,
+			Value:  100000000,
+			Script: alice.WitnessScript,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 99999500, bob.Script, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.SignWithPrivateKeyAndSighash(0, kp, sighashType); err != nil {
+			t.Fatalf("sighash type %d: %v", sighashType, err)
+		}
+		if p.Data.Inputs[0].SighashType != sighashType {
+			t.Fatalf("Got input sighash type %d, expected %d", p.Data.Inputs[0].SighashType, sighashType)
+		}
+		partialSig := p.Data.Inputs[0].PartialSigs[0]
+		if gotSighashType := txscript.SigHashType(partialSig.Signature[len(partialSig.Signature)-1]); gotSighashType != sighashType {
+			t.Fatalf("Got sighash type %d, expected %d", gotSighashType, sighashType)
+		}
+		if err := p.SignWithPrivateKeyAndSighash(0, kp, txscript.SigHashAll); err == nil {
+			t.Fatal("Should have failed with mismatching sighash type of a signed input")
+		}
+		if err := p.FinalizeAll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAddIssuance(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	contractHash := sha256.Sum256([]byte("contract"))
+	inputHash := hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+
+	for _, blinded := range []bool{false, true} {
+		p := NewPartial(&network.Regtest)
+		err := p.AddInput(inputHash, 0, &WitnessUtxo{
+			Asset:  network.Regtest.AssetID,
+			Value:  100000000,
+			Script: alice.WitnessScript,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 99999500, alice.Script, false); err != nil {
+			t.Fatal(err)
+		}
+
+		asset, token, err := p.AddIssuance(0, IssuanceArgs{
+			AssetAmount:  1000,
+			TokenAmount:  1,
+			ContractHash: contractHash[:],
+			AssetScript:  alice.Script,
+			TokenScript:  alice.Script,
+			Blinded:      blinded,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := p.AddIssuance(0, IssuanceArgs{AssetAmount: 1000, AssetScript: alice.Script}); err == nil {
+			t.Fatal("Should have failed adding a second issuance to the same input")
+		}
+
+		input := p.Data.UnsignedTx.Inputs[0]
+		issuance := transaction.NewTxIssuanceFromContractHash(contractHash[:])
+		if err := issuance.GenerateEntropy(input.Hash, input.Index); err != nil {
+			t.Fatal(err)
+		}
+		wantAsset, _ := issuance.GenerateAsset()
+		wantTokenFlag := uint(0)
+		if blinded {
+			wantTokenFlag = 1
+		}
+		wantToken, _ := issuance.GenerateReissuanceToken(wantTokenFlag)
+		if asset != assetHashToHex(wantAsset) {
+			t.Fatalf("Got asset %s, expected %s", asset, assetHashToHex(wantAsset))
+		}
+		if token != assetHashToHex(wantToken) {
+			t.Fatalf("Got token %s, expected %s", token, assetHashToHex(wantToken))
+		}
+		if len(p.Data.UnsignedTx.Outputs) != 3 {
+			t.Fatalf("Got %d outputs, expected 3", len(p.Data.UnsignedTx.Outputs))
+		}
+		if !bytes.Equal(p.Data.UnsignedTx.Outputs[1].Asset[1:], wantAsset) {
+			t.Fatal("Issued asset output has wrong asset")
+		}
+		if !bytes.Equal(p.Data.UnsignedTx.Outputs[2].Asset[1:], wantToken) {
+			t.Fatal("Reissuance token output has wrong asset")
+		}
+
+		if blinded {
+			blindingPubKeys := [][]byte{
+				kpBlind.PublicKey.SerializeCompressed(),
+				kpBlind.PublicKey.SerializeCompressed(),
+				kpBlind.PublicKey.SerializeCompressed(),
+			}
+			issuanceKeys := []pset.IssuanceBlindingPrivateKeys{{
+				AssetKey: kpBlind.PrivateKey.Serialize(),
+				TokenKey: kpBlind.PrivateKey.Serialize(),
+			}}
+			err := p.BlindWithIssuanceKeys([][]byte{kpBlind.PrivateKey.Serialize()}, blindingPubKeys, issuanceKeys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Data.UnsignedTx.Inputs[0].IssuanceRangeProof) == 0 {
+				t.Fatal("Got empty issuance range proof, expected not empty")
+			}
+			if err := p.VerifyBlinding(nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := p.AddFeeOutput(500); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SignWithPrivateKey(0, kp); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.FinalizeAll(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.ExtractHex(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBlindIssuanceMismatch(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	inputHash := hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+	issuanceKeys := []pset.IssuanceBlindingPrivateKeys{{
+		AssetKey: kpBlind.PrivateKey.Serialize(),
+		TokenKey: kpBlind.PrivateKey.Serialize(),
+	}}
+
+	tests := []struct {
+		name         string
+		blinded      bool
+		tokenAmount  uint64
+		issuanceKeys []pset.IssuanceBlindingPrivateKeys
+	}{
+		{"blinded issuance without keys", true, 1, nil},
+		{"unblinded issuance with keys", false, 1, issuanceKeys},
+		{"blinded issuance without token and keys", true, 0, nil},
+		{"unblinded issuance without token with keys", false, 0, issuanceKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(inputHash, 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 100000000, alice.Script, false); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = p.AddIssuance(0, IssuanceArgs{
+				AssetAmount: 1000,
+				TokenAmount: tt.tokenAmount,
+				AssetScript: alice.Script,
+				TokenScript: alice.Script,
+				Blinded:     tt.blinded,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blindingPubKeys := make([][]byte, 0, len(p.Data.Outputs))
+			for range p.Data.Outputs {
+				blindingPubKeys = append(blindingPubKeys, blindingPubKey)
+			}
+			err = p.BlindWithIssuanceKeys(
+				[][]byte{kpBlind.PrivateKey.Serialize()},
+				blindingPubKeys,
+				tt.issuanceKeys,
+			)
+			if err == nil || !strings.Contains(err.Error(), "issuance has been added as") {
+				t.Fatalf("Should have failed with issuance blinding mismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAddReissuance(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	inputHash := hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+
+	// confidential issuance, the reissuance token output is blinded
+	issuanceTx := NewPartial(&network.Regtest)
+	err = issuanceTx.AddInput(inputHash, 0, &WitnessUtxo{
+		Asset:  network.Regtest.AssetID,
+		Value:  100000000,
+		Script: alice.WitnessScript,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issuanceTx.AddOutput(network.Regtest.AssetID, 100000000, alice.Script, false); err != nil {
+		t.Fatal(err)
+	}
+	asset, token, err := issuanceTx.AddIssuance(0, IssuanceArgs{
+		AssetAmount: 1000,
+		TokenAmount: 1,
+		AssetScript: alice.Script,
+		TokenScript: alice.Script,
+		Blinded:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = issuanceTx.BlindWithIssuanceKeys(
+		[][]byte{kpBlind.PrivateKey.Serialize()},
+		[][]byte{blindingPubKey, blindingPubKey, blindingPubKey},
+		[]pset.IssuanceBlindingPrivateKeys{{
+			AssetKey: kpBlind.PrivateKey.Serialize(),
+			TokenKey: kpBlind.PrivateKey.Serialize(),
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issuanceTx.VerifyBlinding(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenOutput := issuanceTx.Data.UnsignedTx.Outputs[2]
+	unblindedToken := testutil.UnblindOutput(t, tokenOutput, kpBlind.PrivateKey.Serialize())
+	entropy, err := IssuanceEntropy(inputHash, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenUtxo := &ConfidentialWitnessUtxo{
+		AssetCommitment: hex.EncodeToString(tokenOutput.Asset),
+		ValueCommitment: hex.EncodeToString(tokenOutput.Value),
+		Script:          tokenOutput.Script,
+		Nonce:           tokenOutput.Nonce,
+		RangeProof:      tokenOutput.RangeProof,
+		SurjectionProof: tokenOutput.SurjectionProof,
+	}
+	issuanceTxHash := issuanceTx.Data.UnsignedTx.TxHash()
+
+	p := NewPartial(&network.Regtest)
+	wrongEntropy := sha256.Sum256([]byte("entropy"))
+	_, _, err = p.AddReissuance(issuanceTxHash.String(), 2, tokenUtxo, ReissuanceArgs{
+		AssetAmount:         500,
+		TokenAmount:         unblindedToken.Value,
+		TokenBlindingFactor: unblindedToken.AssetBlindingFactor,
+		AssetEntropy:        wrongEntropy[:],
+		AssetScript:         alice.Script,
+		TokenScript:         alice.Script,
+	})
+	if err == nil {
+		t.Fatal("Should have failed with wrong asset entropy")
+	}
+	if len(p.Data.Inputs) != 0 {
+		t.Fatal("Partial should be left untouched on failure")
+	}
+
+	reissuedAsset, returnedToken, err := p.AddReissuance(issuanceTxHash.String(), 2, tokenUtxo, ReissuanceArgs{
+		AssetAmount:         500,
+		TokenAmount:         unblindedToken.Value,
+		TokenBlindingFactor: unblindedToken.AssetBlindingFactor,
+		AssetEntropy:        entropy,
+		AssetScript:         alice.Script,
+		TokenScript:         alice.Script,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reissuedAsset != asset {
+		t.Fatalf("Got asset %s, expected %s", reissuedAsset, asset)
+	}
+	if returnedToken != token {
+		t.Fatalf("Got token %s, expected %s", returnedToken, token)
+	}
+
+	issuance := p.Data.UnsignedTx.Inputs[0].Issuance
+	if !bytes.Equal(issuance.AssetBlindingNonce, unblindedToken.AssetBlindingFactor) {
+		t.Fatal("Reissuance nonce does not match token blinding factor")
+	}
+	if !bytes.Equal(issuance.AssetEntropy, entropy) {
+		t.Fatal("Reissuance entropy does not match asset entropy")
+	}
+
+	err = p.BlindWithKeys(
+		[][]byte{kpBlind.PrivateKey.Serialize()},
+		[][]byte{blindingPubKey, blindingPubKey},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.VerifyBlinding(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	wantOutputs := []struct {
+		asset string
+		value uint64
+	}{
+		{asset, 500},
+		{token, unblindedToken.Value},
+	}
+	for i, want := range wantOutputs {
+		unblinded := testutil.UnblindOutput(t, p.Data.UnsignedTx.Outputs[i], kpBlind.PrivateKey.Serialize())
+		if assetHashToHex(unblinded.Asset) != want.asset {
+			t.Fatalf("Output %d: got asset %s, expected %s", i, assetHashToHex(unblinded.Asset), want.asset)
+		}
+		if unblinded.Value != want.value {
+			t.Fatalf("Output %d: got value %d, expected %d", i, unblinded.Value, want.value)
+		}
+	}
+}
+
+func TestAddBurnOutput(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	inputHash := hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+
+	tests := []struct {
+		name       string
+		burnAmount uint64
+		wantErr    bool
+	}{
+		{"burn", 39999500, false},
+		{"burn exceeding inputs", 40000001, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(inputHash, 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 60000000, alice.Script, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddBurnOutput(network.Regtest.AssetID, 0); err == nil {
+				t.Fatal("Should have failed burning 0 amount")
+			}
+			if err := p.AddBurnOutput(network.Regtest.AssetID, tt.burnAmount); err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.BlindWithKeys(
+				[][]byte{kpBlind.PrivateKey.Serialize()},
+				[][]byte{blindingPubKey, blindingPubKey},
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Should have failed blinding unbalanced transaction")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outputs := p.Data.UnsignedTx.Outputs
+			if !outputs[0].IsConfidential() {
+				t.Fatal("Got explicit output, expected confidential")
+			}
+			if outputs[1].IsConfidential() {
+				t.Fatal("Got confidential burn output, expected explicit")
+			}
+			if !bytes.Equal(outputs[1].Script, []byte{txscript.OP_RETURN}) {
+				t.Fatalf("Got burn script %x, expected OP_RETURN", outputs[1].Script)
+			}
+
+			if err := p.AddFeeOutput(500); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SignWithPrivateKey(0, kp); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.FinalizeAll(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.ExtractHex(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAddFeeOutput(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	inputHash := hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32))
+	otherAsset := hex.EncodeToString(bytes.Repeat([]byte{0x02}, 32))
+
+	tests := []struct {
+		name string
+		// addOutputs adds the outputs spending the 1 L-BTC input
+		addOutputs func(p *Partial) error
+		blind      bool
+		wantErr    bool
+	}{
+		{
+			name: "explicit",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddOutput(network.Regtest.AssetID, 99999500, alice.Script, false); err != nil {
+					return err
+				}
+				return p.AddFeeOutput(500)
+			},
+		},
+		{
+			name: "blinded with fee",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddOutput(network.Regtest.AssetID, 99999500, alice.Script, false); err != nil {
+					return err
+				}
+				return p.AddFeeOutput(500)
+			},
+			blind: true,
+		},
+		{
+			name: "missing fee",
+			addOutputs: func(p *Partial) error {
+				return p.AddOutput(network.Regtest.AssetID, 100000000, alice.Script, false)
+			},
+			wantErr: true,
+		},
+		{
+			name: "multiple fees",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddFeeOutput(500); err != nil {
+					return err
+				}
+				if err := p.AddOutput(network.Regtest.AssetID, 500, []byte{}, false); err != nil {
+					return err
+				}
+				return p.AddOutput(network.Regtest.AssetID, 99999000, alice.Script, false)
+			},
+			wantErr: true,
+		},
+		{
+			name: "fee not in policy asset",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddOutput(network.Regtest.AssetID, 100000000, alice.Script, false); err != nil {
+					return err
+				}
+				return p.AddOutput(otherAsset, 500, []byte{}, false)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unbalanced explicit",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddOutput(network.Regtest.AssetID, 99999000, alice.Script, false); err != nil {
+					return err
+				}
+				return p.AddFeeOutput(500)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unbalanced blinded",
+			addOutputs: func(p *Partial) error {
+				if err := p.AddOutput(network.Regtest.AssetID, 99999000, alice.Script, false); err != nil {
+					return err
+				}
+				return p.AddFeeOutput(500)
+			},
+			blind:   true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(inputHash, 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.addOutputs(p); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.blind {
+				blindingPubKeys := make([][]byte, len(p.Data.Outputs))
+				for i := range blindingPubKeys {
+					blindingPubKeys[i] = kpBlind.PublicKey.SerializeCompressed()
+				}
+				err := p.BlindWithKeys([][]byte{kpBlind.PrivateKey.Serialize()}, blindingPubKeys)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("Should have failed blinding")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p.Data.UnsignedTx.Outputs[1].IsConfidential() {
+					t.Fatal("Got confidential fee output, expected explicit")
+				}
+			}
+
+			if err := p.SignWithPrivateKey(0, kp); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.FinalizeAll(); err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.Extract()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Should have failed extracting transaction")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	p := NewPartial(&network.Regtest)
+	if err := p.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFeeOutput(500); err == nil {
+		t.Fatal("Should have failed adding a second fee output")
+	}
+}
+
+func TestSetFeeWithRate(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	bob := payment.FromPublicKey(kpBlind.PublicKey, &network.Regtest, nil)
+
+	for _, blindOutputs := range []bool{false, true} {
+		estimateArgs := EstimateArgs{}
+		if blindOutputs {
+			estimateArgs.BlindedOutputs = []int{0, 1}
+		}
+		p := NewPartial(&network.Regtest)
+		for i := 0; i < 2; i++ {
+			err := p.AddInput(hex.EncodeToString(bytes.Repeat([]byte{byte(i + 1)}, 32)), 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 150000000, bob.WitnessScript, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddOutput(network.Regtest.AssetID, 50000000, alice.WitnessScript, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := p.SetFeeWithRate(0.1, 2, estimateArgs); err == nil {
+			t.Fatal("Should have failed with change index out of range")
+		}
+		if _, err := p.SetFeeWithRate(0.1, 1, estimateArgs); err != nil {
+			t.Fatal(err)
+		}
+		// setting the fee again adjusts the existing fee output
+		fee, err := p.SetFeeWithRate(0.1, 1, estimateArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Data.Outputs) != 3 {
+			t.Fatalf("Got %d outputs, expected 3", len(p.Data.Outputs))
+		}
+		if _, err := p.SetFeeWithRate(0.1, 2, estimateArgs); err == nil {
+			t.Fatal("Should have failed using the fee output as change")
+		}
+
+		estimatedSize, err := p.EstimateVirtualSize(estimateArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantFee := uint64(math.Ceil(float64(estimatedSize) * 0.1)); fee != wantFee {
+			t.Fatalf("Got fee %d, expected %d", fee, wantFee)
+		}
+
+		if blindOutputs {
+			blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+			err := p.BlindWithKeys(
+				[][]byte{kpBlind.PrivateKey.Serialize(), kpBlind.PrivateKey.Serialize()},
+				[][]byte{blindingPubKey, blindingPubKey, blindingPubKey},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := range p.Data.Inputs {
+			if err := p.SignWithPrivateKey(i, kp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.FinalizeAll(); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := p.Extract()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// estimation uses the max signature size, thus it can exceed the actual
+		// size by a few bytes per input
+		actualSize := tx.VirtualSize()
+		if estimatedSize < actualSize || estimatedSize > actualSize+len(tx.Inputs) {
+			t.Fatalf("Got estimated size %d, expected about %d", estimatedSize, actualSize)
+		}
+	}
+}
+
+func TestEstimateBlindedIssuance(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+
+	p := NewPartial(&network.Regtest)
+	err = p.AddInput(hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), 0, &WitnessUtxo{
+		Asset:  network.Regtest.AssetID,
+		Value:  100000000,
+		Script: alice.WitnessScript,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddOutput(network.Regtest.AssetID, 99999500, alice.WitnessScript, false); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = p.AddIssuance(0, IssuanceArgs{
+		AssetAmount: 1000,
+		TokenAmount: 1,
+		AssetScript: alice.WitnessScript,
+		TokenScript: alice.WitnessScript,
+		Blinded:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+
+	invalidArgs := []EstimateArgs{
+		{BlindedOutputs: []int{3}},
+		{BlindedOutputs: []int{4}},
+		{BlindedIssuances: []int{1}},
+	}
+	for _, args := range invalidArgs {
+		if _, err := p.EstimateVirtualSize(args); err == nil {
+			t.Fatalf("Should have failed estimating with args %+v", args)
+		}
+	}
+
+	outputsOnlySize, err := p.EstimateVirtualSize(EstimateArgs{BlindedOutputs: []int{0, 1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimatedSize, err := p.EstimateVirtualSize(EstimateArgs{
+		BlindedOutputs:   []int{0, 1, 2},
+		BlindedIssuances: []int{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// issuance and inflation range proofs are witness data
+	if estimatedSize-outputsOnlySize < 2*rangeProofSize/4 {
+		t.Fatalf("Got estimated size %d, issuance range proofs not accounted", estimatedSize)
+	}
+
+	err = p.BlindWithIssuanceKeys(
+		[][]byte{kpBlind.PrivateKey.Serialize()},
+		[][]byte{blindingPubKey, blindingPubKey, blindingPubKey, nil},
+		[]pset.IssuanceBlindingPrivateKeys{{
+			AssetKey: kpBlind.PrivateKey.Serialize(),
+			TokenKey: kpBlind.PrivateKey.Serialize(),
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SignWithPrivateKey(0, kp); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.FinalizeAll(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := p.Extract()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actualSize := tx.VirtualSize()
+	if estimatedSize < actualSize || estimatedSize > actualSize+len(tx.Inputs) {
+		t.Fatalf("Got estimated size %d, expected about %d", estimatedSize, actualSize)
+	}
+}
+
+func TestBlindWithUnblindedInputs(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobBlind, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	aliceBlindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+	bobBlindingPubKey := bobBlind.PubKey().SerializeCompressed()
+
+	// funding transaction with a confidential output for each party
+	prevTx := NewPartial(&network.Regtest)
+	err = prevTx.AddInput(hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), 0, &WitnessUtxo{
+		Asset:  network.Regtest.AssetID,
+		Value:  100000000,
+		Script: alice.WitnessScript,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []uint64{50000000, 49999500} {
+		if err := prevTx.AddOutput(network.Regtest.AssetID, value, alice.WitnessScript, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := prevTx.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+	err = prevTx.BlindWithKeys(
+		[][]byte{kpBlind.PrivateKey.Serialize()},
+		[][]byte{aliceBlindingPubKey, bobBlindingPubKey, nil},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevTxHash := prevTx.Data.UnsignedTx.TxHash()
+	bobUnblinded := testutil.UnblindOutput(t, prevTx.Data.UnsignedTx.Outputs[1], bobBlind.Serialize())
+
+	tests := []struct {
+		name         string
+		valueBlinder []byte
+		wantErr      bool
+	}{
+		{"matching blinders", bobUnblinded.ValueBlindingFactor, false},
+		{"wrong value blinder", bytes.Repeat([]byte{0x01}, 32), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			for i, output := range prevTx.Data.UnsignedTx.Outputs[:2] {
+				err := p.AddBlindedInput(prevTxHash.String(), uint32(i), &ConfidentialWitnessUtxo{
+					AssetCommitment: hex.EncodeToString(output.Asset),
+					ValueCommitment: hex.EncodeToString(output.Value),
+					Script:          output.Script,
+					Nonce:           output.Nonce,
+					RangeProof:      output.RangeProof,
+					SurjectionProof: output.SurjectionProof,
+				}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 50000000, alice.WitnessScript, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 49999000, alice.WitnessScript, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddFeeOutput(500); err != nil {
+				t.Fatal(err)
+			}
+
+			// the input of bob is blinded against without its blinding key
+			err = p.Blind(BlindArgs{
+				BlindingPrivateKeys: [][]byte{kpBlind.PrivateKey.Serialize(), nil},
+				UnblindedInputs: []*UnblindedInput{nil, {
+					Asset:        network.Regtest.AssetID,
+					Value:        bobUnblinded.Value,
+					AssetBlinder: bobUnblinded.AssetBlindingFactor,
+					ValueBlinder: tt.valueBlinder,
+				}},
+				BlindingPublicKeys: [][]byte{bobBlindingPubKey, aliceBlindingPubKey, nil},
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Should have failed blinding with wrong unblinded input")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			unblinded := testutil.UnblindOutput(t, p.Data.UnsignedTx.Outputs[0], bobBlind.Serialize())
+			if unblinded.Value != 50000000 {
+				t.Fatalf("Got value %d, expected 50000000", unblinded.Value)
+			}
+		})
+	}
+}
+
+func TestBlindSelectedOutputs(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+
+	tests := []struct {
+		name    string
+		keys    map[int][]byte
+		wantErr bool
+	}{
+		{"blind marked output", map[int][]byte{0: blindingPubKey}, false},
+		{"blind marked and explicit outputs", map[int][]byte{0: blindingPubKey, 1: blindingPubKey}, false},
+		{"missing key of marked output", map[int][]byte{1: blindingPubKey}, true},
+		{"blind fee output", map[int][]byte{0: blindingPubKey, 2: blindingPubKey}, true},
+		{"index out of range", map[int][]byte{0: blindingPubKey, 3: blindingPubKey}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 50000000, alice.WitnessScript, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddOutput(network.Regtest.AssetID, 49999500, alice.WitnessScript, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.AddFeeOutput(500); err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Blind(BlindArgs{
+				BlindingPrivateKeys: [][]byte{kpBlind.PrivateKey.Serialize()},
+				OutputBlindingKeys:  tt.keys,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Should have failed blinding outputs")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for index, output := range p.Data.UnsignedTx.Outputs {
+				_, wantConfidential := tt.keys[index]
+				if output.IsConfidential() != wantConfidential {
+					t.Fatalf("Output %d: got confidential %v, expected %v", index, output.IsConfidential(), wantConfidential)
+				}
+			}
+			if err := p.SignWithPrivateKey(0, kp); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.FinalizeAll(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Extract(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyBlinding(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpBlind, err := keypair.FromPrivateKey(aliceBlindHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongBlind, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	blindingPubKey := kpBlind.PublicKey.SerializeCompressed()
+
+	tests := []struct {
+		name        string
+		keys        map[int][]byte
+		tamper      func(p *Partial)
+		wantOutputs []int
+	}{
+		{"stored blinding data", nil, nil, nil},
+		{"recipient keys", map[int][]byte{0: kpBlind.PrivateKey.Serialize(), 1: kpBlind.PrivateKey.Serialize()}, nil, nil},
+		{"wrong recipient key", map[int][]byte{1: wrongBlind.Serialize()}, nil, []int{1}},
+		{"explicit output key", map[int][]byte{2: kpBlind.PrivateKey.Serialize()}, nil, []int{2}},
+		{"unexpected value", nil, func(p *Partial) { p.BlindingData[0].Value++ }, []int{0}},
+		{
+			"replaced output",
+			nil,
+			func(p *Partial) { p.Data.UnsignedTx.Outputs[0] = p.Data.UnsignedTx.Outputs[1] },
+			[]int{0},
+		},
+		{
+			"swapped surjection proofs",
+			nil,
+			func(p *Partial) {
+				outputs := p.Data.UnsignedTx.Outputs
+				outputs[0].SurjectionProof, outputs[1].SurjectionProof = outputs[1].SurjectionProof, outputs[0].SurjectionProof
+			},
+			[]int{0, 1},
+		},
+		{
+			"input of another asset",
+			nil,
+			func(p *Partial) { p.Data.Inputs[0].WitnessUtxo.Asset[1] ^= 0xff },
+			[]int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartial(&network.Regtest)
+			err := p.AddInput(hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), 0, &WitnessUtxo{
+				Asset:  network.Regtest.AssetID,
+				Value:  100000000,
+				Script: alice.WitnessScript,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, value := range []uint64{60000000, 39999500} {
+				if err := p.AddOutput(network.Regtest.AssetID, value, alice.WitnessScript, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.AddFeeOutput(500); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.VerifyBlinding(nil); err == nil {
+				t.Fatal("Should have failed verifying not blinded transaction")
+			}
+			err = p.BlindWithKeys(
+				[][]byte{kpBlind.PrivateKey.Serialize()},
+				[][]byte{blindingPubKey, blindingPubKey, nil},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(p)
+			}
+
+			err = p.VerifyBlinding(tt.keys)
+			if len(tt.wantOutputs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var verificationErr *BlindingVerificationError
+			if !errors.As(err, &verificationErr) {
+				t.Fatalf("Should have failed with BlindingVerificationError, got %v", err)
+			}
+			if len(verificationErr.Outputs) != len(tt.wantOutputs) {
+				t.Fatalf("Got errors %v, expected for outputs %v", verificationErr.Outputs, tt.wantOutputs)
+			}
+			for _, index := range tt.wantOutputs {
+				if verificationErr.Outputs[index] == nil {
+					t.Fatalf("Missing error of output %d", index)
+				}
+			}
+		})
+	}
+}
+
+func TestBlindWithMasterBlindingKey(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := keypair.NewMasterBlindingKey(bytes.Repeat([]byte{0x01}, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+
+	// funding transaction with a confidential output blinded with the key
+	// derived from its script
+	prevTx := NewPartial(&network.Regtest)
+	err = prevTx.AddInput(hex.EncodeToString(bytes.Repeat([]byte{0x01}, 32)), 0, &WitnessUtxo{
+		Asset:  network.Regtest.AssetID,
+		Value:  100000000,
+		Script: alice.WitnessScript,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prevTx.AddOutput(network.Regtest.AssetID, 99999500, alice.WitnessScript, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := prevTx.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+	blindingPubKeys, err := prevTx.BlindingPublicKeys(master.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindingPrivKeys, err := prevTx.BlindingPrivateKeys(master.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blindingPrivKeys[0] != nil || blindingPubKeys[1] != nil {
+		t.Fatal("Keys of explicit input and fee output should be nil")
+	}
+	if err := prevTx.BlindWithKeys(blindingPrivKeys, blindingPubKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	// the confidential prevout is unblinded with the derived key
+	prevout := prevTx.Data.UnsignedTx.Outputs[0]
+	prevTxHash := prevTx.Data.UnsignedTx.TxHash()
+	p := NewPartial(&network.Regtest)
+	err = p.AddBlindedInput(prevTxHash.String(), 0, &ConfidentialWitnessUtxo{
+		AssetCommitment: hex.EncodeToString(prevout.Asset),
+		ValueCommitment: hex.EncodeToString(prevout.Value),
+		Script:          prevout.Script,
+		Nonce:           prevout.Nonce,
+		RangeProof:      prevout.RangeProof,
+		SurjectionProof: prevout.SurjectionProof,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddOutput(network.Regtest.AssetID, 99999000, alice.WitnessScript, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+	if blindingPrivKeys, err = p.BlindingPrivateKeys(master.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if blindingPubKeys, err = p.BlindingPublicKeys(master.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.BlindWithKeys(blindingPrivKeys, blindingPubKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	outputKey, err := master.PrivateKey(alice.WitnessScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.VerifyBlinding(map[int][]byte{0: outputKey}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeAndCombine(t *testing.T) {
+	kp, err := keypair.FromPrivateKey(aliceHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKeyPair, err := keypair.FromPrivateKey(bobHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := payment.FromPublicKey(kp.PublicKey, &network.Regtest, nil)
+	bob := payment.FromPublicKey(bobKeyPair.PublicKey, &network.Regtest, nil)
+
+	p := NewPartial(&network.Regtest)
+	for i, script := range [][]byte{alice.WitnessScript, bob.WitnessScript} {
+		err := p.AddInput(hex.EncodeToString(make([]byte, 32)), uint32(i), &WitnessUtxo{
+			Asset:  network.Regtest.AssetID,
+			Value:  50000000,
+			Script: script,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.AddOutput(network.Regtest.AssetID, 99999500, bob.Script, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFeeOutput(500); err != nil {
+		t.Fatal(err)
+	}
+
+	b64, err := p.ToBase64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	psetHex, err := p.ToHex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	psetBytes, err := p.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aliceSigner, err := FromBase64(b64, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSigner, err := FromHex(psetHex, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combiner, err := FromBytes(psetBytes, &network.Regtest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotB64, _ := combiner.ToBase64(); gotB64 != b64 {
+		t.Fatalf("Got %s, expected %s", gotB64, b64)
+	}
+
+	if err := aliceSigner.SignWithPrivateKey(0, kp); err != nil {
+		t.Fatal(err)
+	}
+	if err := bobSigner.SignWithPrivateKey(1, bobKeyPair); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewPartial(&network.Regtest)
+	if err := combiner.Combine(aliceSigner, other); err == nil {
+		t.Fatal("Should have failed combining different unsigned transactions")
+	}
+	if len(combiner.Data.Inputs[0].PartialSigs) > 0 {
+		t.Fatal("Failed combine should leave the partial untouched")
+	}
+
+	if err := combiner.Combine(aliceSigner, bobSigner); err != nil {
+		t.Fatal(err)
+	}
+	if err := combiner.FinalizeAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !combiner.IsComplete() {
+		t.Fatal("Combined partial should be complete")
+	}
+}
+
 func TestCreatePsetWithBlindedInput(t *testing.T) {
 	explorerURL, ok := os.LookupEnv("API_URL")
 	if !ok {
@@ -112,7 +1648,7 @@ func TestCreatePsetWithBlindedInput(t *testing.T) {
 	}
 
 	coins := &coinselect.Coins{Utxos: utxos, BlindingKey: kpBlind.PrivateKey.Serialize()}
-	selectedUtxos, change, err := coins.CoinSelect(50000000, network.Regtest.AssetID)
+	selectedUtxos, change, _, err := coins.CoinSelect(50000000, network.Regtest.AssetID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -149,35 +1685,29 @@ func TestCreatePsetWithBlindedInput(t *testing.T) {
 
 	   	fmt.Println(b64) */
 
-	p.AddOutput(network.Regtest.AssetID, fee, []byte{}, false)
+	p.AddFeeOutput(fee)
 
 	err = p.SignWithPrivateKey(0, kp)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	pFinalized := p.Data
-	err = pset.FinalizeAll(pFinalized)
+	err = p.FinalizeAll()
 	if err != nil {
-		t.Errorf("sign: %w", err)
+		t.Fatal(err)
 	}
 
-	if !pFinalized.IsComplete() {
-		t.Errorf("pset not complete: %w", err)
+	if !p.IsComplete() {
+		t.Fatal("pset not complete")
 	}
 
-	err = pFinalized.SanityCheck()
-	if err != nil {
-		t.Errorf("sanity check: %w", err)
-	}
-	// Extract the final signed transaction from the Pset wrapper.
-	finalTx, err := pset.Extract(pFinalized)
+	err = p.Data.SanityCheck()
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("sanity check: %v", err)
 	}
 
-	// Serialize the transaction and try to broadcast.
-	txHex, err := finalTx.ToHex()
+	// Serialize the final signed transaction and try to broadcast.
+	txHex, err := p.ExtractHex()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -214,3 +1744,19 @@ func faucet(address string) (string, error) {
 
 	return respBody["txId"], nil
 }
+
+func newPrevTx(t *testing.T, script []byte, value uint64) *transaction.Transaction {
+	t.Helper()
+	elementsValue, err := confidential.SatoshiToElementsValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := AssetHashToBytes(network.Regtest.AssetID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := transaction.NewTx(2)
+	tx.AddInput(transaction.NewTxInput(make([]byte, 32), 0))
+	tx.AddOutput(transaction.NewTxOutput(asset, elementsValue[:], script))
+	return tx
+}
@@ -0,0 +1,275 @@
+package partial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vulpemventures/go-elements/confidential"
+	"github.com/vulpemventures/go-secp256k1-zkp"
+)
+
+const (
+	// generatorSize is the size of a secp256k1 generator in memory
+	generatorSize           = 64
+	maxGeneratorAllocations = 10
+	// maxSurjectionProofAttempts is the number of times the blinder creates
+	// a surjection proof before giving up
+	maxSurjectionProofAttempts = 10
+)
+
+// OutputBlindingData defines the asset and value an output has been blinded
+// with, along with the blinding factors and the nonce its range proof can be
+// rewound with
+type OutputBlindingData struct {
+	Asset        string
+	Value        uint64
+	AssetBlinder []byte
+	ValueBlinder []byte
+	Nonce        []byte
+}
+
+// BlindingVerificationError is returned when some blinded outputs do not
+// unblind to the asset and value they were meant to. It holds the error of
+// each of these outputs by index
+type BlindingVerificationError struct {
+	Outputs map[int]error
+}
+
+func (e *BlindingVerificationError) Error() string {
+	indexes := make([]int, 0, len(e.Outputs))
+	for index := range e.Outputs {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		messages = append(messages, fmt.Sprintf("output %d: %s", index, e.Outputs[index]))
+	}
+	return "blinding verification failed: " + strings.Join(messages, "; ")
+}
+
+// VerifyBlinding unblinds every output blinded by this Partial and checks it
+// against the asset and value it was meant to carry, so that funds are not
+// sent to outputs the recipients can not unblind. Outputs are unblinded with
+// the private blinding keys of the recipients, by output index, or with the
+// stored blinding data for the outputs without key. The surjection proof of
+// every confidential output is verified against the assets of the inputs and
+// of their issuances. If some outputs do not match, a
+// *BlindingVerificationError is returned.
+//
+// Without the key of an output, its range proof is rewound with the nonce
+// stored while blinding: commitments and proofs not matching the blinded
+// asset and value are detected, while a nonce derived from a wrong blinding
+// public key is not, since only the private key of the recipient reveals it.
+func (p *Partial) VerifyBlinding(blindingPrivateKeys map[int][]byte) error {
+	if len(p.BlindingData) == 0 {
+		return errors.New("missing blinding data, no output has been blinded")
+	}
+
+	outputErrors, err := p.verifySurjectionProofs()
+	if err != nil {
+		return err
+	}
+	for index, data := range p.BlindingData {
+		if _, ok := outputErrors[index]; ok {
+			continue
+		}
+		if err := p.verifyOutput(index, data, blindingPrivateKeys[index]); err != nil {
+			outputErrors[index] = err
+		}
+	}
+	for index := range blindingPrivateKeys {
+		if _, ok := p.BlindingData[index]; !ok {
+			outputErrors[index] = errors.New("output has not been blinded")
+		}
+	}
+
+	if len(outputErrors) > 0 {
+		return &BlindingVerificationError{outputErrors}
+	}
+	return nil
+}
+
+// verifyOutput unblinds the output at the given index, with the private
+// blinding key if given or with the nonce of the blinding data otherwise, and
+// compares the result with the blinding data
+func (p *Partial) verifyOutput(index int, data *OutputBlindingData, blindingPrivateKey []byte) error {
+	outputs := p.Data.UnsignedTx.Outputs
+	if index < 0 || index > len(outputs)-1 {
+		return errors.New("index out of range")
+	}
+	output := outputs[index]
+	if !output.IsConfidential() {
+		return errors.New("output is not confidential")
+	}
+
+	nonce := data.Nonce
+	if len(blindingPrivateKey) > 0 {
+		nonceHash, err := confidential.NonceHash(output.Nonce, blindingPrivateKey)
+		if err != nil {
+			return err
+		}
+		nonce = nonceHash[:]
+	}
+	var nonce32 [32]byte
+	copy(nonce32[:], nonce)
+
+	unblinded, err := confidential.UnblindOutput(confidential.UnblindOutputArg{
+		Nonce:           nonce32,
+		Rangeproof:      output.RangeProof,
+		ValueCommitment: output.Value,
+		AssetCommitment: output.Asset,
+		ScriptPubkey:    output.Script,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to unblind: %w", err)
+	}
+
+	if asset := assetHashToHex(unblinded.Asset); asset != data.Asset {
+		return fmt.Errorf("got asset %s, expected %s", asset, data.Asset)
+	}
+	if unblinded.Value != data.Value {
+		return fmt.Errorf("got value %d, expected %d", unblinded.Value, data.Value)
+	}
+	if !bytes.Equal(unblinded.AssetBlindingFactor, data.AssetBlinder) {
+		return errors.New("asset blinder does not match")
+	}
+	if !bytes.Equal(unblinded.ValueBlindingFactor, data.ValueBlinder) {
+		return errors.New("value blinder does not match")
+	}
+	return nil
+}
+
+// verifySurjectionProofs verifies the surjection proof of every confidential
+// output and returns the error of each invalid one by index
+func (p *Partial) verifySurjectionProofs() (map[int]error, error) {
+	inputCommitments, err := p.surjectionInputCommitments()
+	if err != nil {
+		return nil, err
+	}
+
+	outputErrors := make(map[int]error)
+	for index, output := range p.Data.UnsignedTx.Outputs {
+		if !output.IsConfidential() {
+			continue
+		}
+		err := verifySurjectionProof(output.SurjectionProof, output.Asset, inputCommitments)
+		if err != nil {
+			outputErrors[index] = err
+		}
+	}
+	return outputErrors, nil
+}
+
+// surjectionInputCommitments returns the asset commitments the output
+// surjection proofs are created against: the assets of the inputs, followed
+// by the assets and tokens of their issuances, in the order of the blinder
+func (p *Partial) surjectionInputCommitments() ([][]byte, error) {
+	assets := make([][]byte, 0, len(p.Data.Inputs))
+	for index := range p.Data.Inputs {
+		prevout, err := prevoutOf(p.Data, index)
+		if err != nil {
+			return nil, err
+		}
+		asset := prevout.Asset
+		if !prevout.IsConfidential() {
+			asset = asset[1:]
+		}
+		assets = append(assets, asset)
+	}
+
+	for index, input := range p.Data.UnsignedTx.Inputs {
+		if !input.HasIssuance() {
+			continue
+		}
+		// the token is derived as confidential if the asset amount is a
+		// commitment, as done by the consensus rules
+		blinded := len(input.Issuance.AssetAmount) == 33
+		asset, token, err := issuanceAssets(input, blinded)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", index, err)
+		}
+		if len(input.Issuance.AssetAmount) > 1 {
+			assets = append(assets, asset)
+		}
+		if len(input.Issuance.TokenAmount) > 1 {
+			assets = append(assets, token)
+		}
+	}
+
+	commitments := make([][]byte, 0, len(assets))
+	for _, asset := range assets {
+		if len(asset) == 33 {
+			commitments = append(commitments, asset)
+			continue
+		}
+		commitment, err := confidential.AssetCommitment(asset, make([]byte, 32))
+		if err != nil {
+			return nil, err
+		}
+		commitments = append(commitments, commitment[:])
+	}
+	return commitments, nil
+}
+
+// verifySurjectionProof checks that the surjection proof proves the output
+// asset commitment to be one of the input asset commitments
+func verifySurjectionProof(surjectionProof, outputCommitment []byte, inputCommitments [][]byte) error {
+	ctx, _ := secp256k1.ContextCreate(secp256k1.ContextBoth)
+	defer secp256k1.ContextDestroy(ctx)
+
+	outputTag, err := secp256k1.GeneratorParse(ctx, outputCommitment)
+	if err != nil {
+		return fmt.Errorf("invalid asset commitment: %w", err)
+	}
+	proof, err := secp256k1.SurjectionProofParse(ctx, surjectionProof)
+	if err != nil {
+		return fmt.Errorf("invalid surjection proof: %w", err)
+	}
+	inputTags, err := parseGenerators(ctx, inputCommitments)
+	if err != nil {
+		return err
+	}
+	if !secp256k1.SurjectionProofVerify(ctx, proof, inputTags, *outputTag) {
+		return errors.New("surjection proof does not match input assets")
+	}
+	return nil
+}
+
+// parseGenerators parses the given asset commitments. The secp256k1 binding
+// reads the generators as a contiguous array starting from the first one,
+// thus they are parsed again until adjacent in memory
+func parseGenerators(ctx *secp256k1.Context, commitments [][]byte) ([]secp256k1.Generator, error) {
+	for attempt := 0; attempt < maxGeneratorAllocations; attempt++ {
+		generators := make([]secp256k1.Generator, 0, len(commitments))
+		for _, commitment := range commitments {
+			generator, err := secp256k1.GeneratorParse(ctx, commitment)
+			if err != nil {
+				return nil, err
+			}
+			generators = append(generators, *generator)
+		}
+		if adjacentGenerators(generators) {
+			return generators, nil
+		}
+	}
+	return nil, errors.New("unable to allocate surjection proof input tags")
+}
+
+// adjacentGenerators returns whether the underlying secp256k1 generators are
+// stored one after the other
+func adjacentGenerators(generators []secp256k1.Generator) bool {
+	for i := 1; i < len(generators); i++ {
+		prev := reflect.ValueOf(generators[i-1]).Field(0).Pointer()
+		curr := reflect.ValueOf(generators[i]).Field(0).Pointer()
+		if curr-prev != generatorSize {
+			return false
+		}
+	}
+	return true
+}
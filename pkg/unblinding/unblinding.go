@@ -0,0 +1,177 @@
+package unblinding
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tiero/ocean/internal/bufferutil"
+	"github.com/tiero/ocean/pkg/explorer"
+	"github.com/vulpemventures/go-elements/confidential"
+)
+
+// BlindingKeyLookup returns the private blinding key for the given output
+// script
+type BlindingKeyLookup func(script []byte) ([]byte, error)
+
+// UnblindedUtxo defines an utxo with its revealed asset, value and blinding
+// factors. Blinding factors of unconfidential utxos are zero
+type UnblindedUtxo struct {
+	Utxo         explorer.Utxo
+	Asset        string
+	Value        uint64
+	AssetBlinder []byte
+	ValueBlinder []byte
+}
+
+// IsConfidential returns whether the utxo was confidential
+func (u *UnblindedUtxo) IsConfidential() bool {
+	return isConfidential(u.Utxo)
+}
+
+// Unblinder unblinds utxos with the blinding keys returned by the lookup
+// function, optionally caching the results by outpoint
+type Unblinder struct {
+	BlindingKey BlindingKeyLookup
+
+	mu    sync.RWMutex
+	cache map[string]*UnblindedUtxo
+}
+
+// NewUnblinder returns an Unblinder using the given blinding key lookup
+func NewUnblinder(lookup BlindingKeyLookup) *Unblinder {
+	return &Unblinder{BlindingKey: lookup}
+}
+
+// NewCachedUnblinder returns an Unblinder that unblinds each utxo once and
+// returns the cached result afterwards
+func NewCachedUnblinder(lookup BlindingKeyLookup) *Unblinder {
+	return &Unblinder{
+		BlindingKey: lookup,
+		cache:       make(map[string]*UnblindedUtxo),
+	}
+}
+
+// StaticKey returns a lookup returning the same blinding key for any script
+func StaticKey(blindingKey []byte) BlindingKeyLookup {
+	return func(script []byte) ([]byte, error) {
+		return blindingKey, nil
+	}
+}
+
+// KeysByScript returns a lookup returning the blinding keys of the given
+// map, indexed by hex encoded script
+func KeysByScript(blindingKeys map[string][]byte) BlindingKeyLookup {
+	return func(script []byte) ([]byte, error) {
+		blindingKey, ok := blindingKeys[hex.EncodeToString(script)]
+		if !ok {
+			return nil, fmt.Errorf(
+				"blinding key not found for script %s", hex.EncodeToString(script),
+			)
+		}
+		return blindingKey, nil
+	}
+}
+
+// Unblind returns the given utxo with revealed asset, value and blinding
+// factors
+func (u *Unblinder) Unblind(utxo explorer.Utxo) (*UnblindedUtxo, error) {
+	outpoint := fmt.Sprintf("%s:%d", utxo.Hash(), utxo.Index())
+	if u.cache != nil {
+		u.mu.RLock()
+		unblinded, ok := u.cache[outpoint]
+		u.mu.RUnlock()
+		if ok {
+			return unblinded, nil
+		}
+	}
+
+	var blindingKey []byte
+	if isConfidential(utxo) {
+		if u.BlindingKey == nil {
+			return nil, errors.New("missing blinding key lookup")
+		}
+		var err error
+		if blindingKey, err = u.BlindingKey(utxo.Script()); err != nil {
+			return nil, err
+		}
+	}
+	unblinded, err := UnblindWithKey(utxo, blindingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.cache != nil {
+		u.mu.Lock()
+		u.cache[outpoint] = unblinded
+		u.mu.Unlock()
+	}
+	return unblinded, nil
+}
+
+// UnblindAll unblinds the given utxos
+func (u *Unblinder) UnblindAll(utxos []explorer.Utxo) ([]*UnblindedUtxo, error) {
+	unblinded := make([]*UnblindedUtxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		result, err := u.Unblind(utxo)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.Hash(), utxo.Index(), err)
+		}
+		unblinded = append(unblinded, result)
+	}
+	return unblinded, nil
+}
+
+// UnblindWithKey returns the given utxo unblinded with the given private
+// blinding key. Unconfidential utxos are returned with zero blinding factors
+func UnblindWithKey(utxo explorer.Utxo, blindingKey []byte) (*UnblindedUtxo, error) {
+	if !isConfidential(utxo) {
+		return &UnblindedUtxo{
+			Utxo:         utxo,
+			Asset:        utxo.Asset(),
+			Value:        utxo.Value(),
+			AssetBlinder: make([]byte, 32),
+			ValueBlinder: make([]byte, 32),
+		}, nil
+	}
+
+	if len(blindingKey) == 0 {
+		return nil, errors.New("missing blinding key for confidential utxo")
+	}
+	assetCommitment, err := hex.DecodeString(utxo.AssetCommitment())
+	if err != nil {
+		return nil, err
+	}
+	valueCommitment, err := hex.DecodeString(utxo.ValueCommitment())
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := confidential.NonceHash(utxo.Nonce(), blindingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := confidential.UnblindOutput(confidential.UnblindOutputArg{
+		Nonce:           nonce,
+		Rangeproof:      utxo.RangeProof(),
+		ValueCommitment: valueCommitment,
+		AssetCommitment: assetCommitment,
+		ScriptPubkey:    utxo.Script(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnblindedUtxo{
+		Utxo:         utxo,
+		Asset:        hex.EncodeToString(bufferutil.ReverseBytes(output.Asset[:])),
+		Value:        output.Value,
+		AssetBlinder: output.AssetBlindingFactor,
+		ValueBlinder: output.ValueBlindingFactor,
+	}, nil
+}
+
+func isConfidential(utxo explorer.Utxo) bool {
+	return len(utxo.AssetCommitment()) > 0 && len(utxo.ValueCommitment()) > 0
+}
@@ -0,0 +1,79 @@
+package unblinding
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/tiero/ocean/internal/testutil"
+	"github.com/tiero/ocean/pkg/explorer"
+	"github.com/vulpemventures/go-elements/network"
+	"github.com/vulpemventures/go-elements/payment"
+)
+
+const privateKeyHex = "bfb96a215dfb07d1a193464174b9ea8e91f2a15bba79800dea838add330f6d86"
+const blindingKeyHex = "dd65e215154c13b1c14f9dc0aa7cfc1f40414f214bd0c5dfe2d370880bdf8356"
+
+func TestUnblind(t *testing.T) {
+	key, blindingKey := testutil.NewKeyPair(t, privateKeyHex), testutil.NewKeyPair(t, blindingKeyHex)
+	script := payment.FromPublicKey(key.PublicKey, &network.Regtest, nil).WitnessScript
+	confidentialUtxo := testutil.NewConfidentialUtxo(t, network.Regtest.AssetID, 100000, script, blindingKey)
+	explicitUtxo := testutil.NewExplicitUtxo(network.Regtest.AssetID, 5000, 0x01)
+
+	lookups := map[string]BlindingKeyLookup{
+		"static key": StaticKey(blindingKey.PrivateKey.Serialize()),
+		"keys by script": KeysByScript(map[string][]byte{
+			hex.EncodeToString(script): blindingKey.PrivateKey.Serialize(),
+		}),
+	}
+	for name, lookup := range lookups {
+		t.Run(name, func(t *testing.T) {
+			unblinded, err := NewUnblinder(lookup).UnblindAll(
+				[]explorer.Utxo{confidentialUtxo, explicitUtxo},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !unblinded[0].IsConfidential() || unblinded[1].IsConfidential() {
+				t.Fatal("Wrong confidential status of unblinded utxos")
+			}
+			if unblinded[0].Asset != network.Regtest.AssetID || unblinded[0].Value != 100000 {
+				t.Fatalf("Got asset %s and value %d", unblinded[0].Asset, unblinded[0].Value)
+			}
+			if len(unblinded[0].AssetBlinder) != 32 || len(unblinded[0].ValueBlinder) != 32 {
+				t.Fatal("Missing blinding factors of confidential utxo")
+			}
+			if unblinded[1].Value != 5000 || !bytes.Equal(unblinded[1].AssetBlinder, make([]byte, 32)) {
+				t.Fatal("Wrong unblinded explicit utxo")
+			}
+		})
+	}
+
+	missingKey := KeysByScript(map[string][]byte{})
+	if _, err := NewUnblinder(missingKey).Unblind(confidentialUtxo); err == nil {
+		t.Fatal("Should have failed without blinding key")
+	}
+}
+
+func TestCachedUnblinder(t *testing.T) {
+	key, blindingKey := testutil.NewKeyPair(t, privateKeyHex), testutil.NewKeyPair(t, blindingKeyHex)
+	script := payment.FromPublicKey(key.PublicKey, &network.Regtest, nil).WitnessScript
+	utxo := testutil.NewConfidentialUtxo(t, network.Regtest.AssetID, 100000, script, blindingKey)
+
+	lookups := 0
+	unblinder := NewCachedUnblinder(func(script []byte) ([]byte, error) {
+		lookups++
+		return blindingKey.PrivateKey.Serialize(), nil
+	})
+	first, err := unblinder.Unblind(utxo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := unblinder.Unblind(utxo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 1 || first != second {
+		t.Fatalf("Got %d lookups, expected the second result to be cached", lookups)
+	}
+}